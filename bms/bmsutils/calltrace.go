@@ -0,0 +1,107 @@
+package bmsutils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// CallFrame is a call made during a transaction, as reported by the callTracer.
+type CallFrame struct {
+	Type         string          `json:"type"`
+	From         common.Address  `json:"from"`
+	To           *common.Address `json:"to,omitempty"`
+	Value        *hexutil.Big    `json:"value,omitempty"`
+	Gas          hexutil.Uint64  `json:"gas"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	RevertReason string          `json:"revertReason,omitempty"`
+	Calls        []*CallFrame    `json:"calls,omitempty"`
+}
+
+// Failed reports whether the call reverted or ran out of gas.
+func (frame *CallFrame) Failed() bool {
+	return frame.Error != ""
+}
+
+// Reason returns the decoded revert reason of a failed call.
+func (frame *CallFrame) Reason() string {
+	if err := UnpackRevert(frame.Output); err != nil {
+		return err.Error()
+	}
+	if frame.RevertReason != "" {
+		return frame.RevertReason
+	}
+	if reason, err := abi.UnpackRevert(frame.Output); err == nil {
+		return reason
+	}
+	return frame.Error
+}
+
+// String returns the call tree with every call decoded through the enrolled ABIs.
+func (frame *CallFrame) String() string {
+	var builder strings.Builder
+	frame.write(&builder, 0, frame.RevertPoint())
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+func (frame *CallFrame) write(builder *strings.Builder, depth int, point *CallFrame) {
+	builder.WriteString(strings.Repeat("  ", depth))
+	builder.WriteString(frame.Type)
+	builder.WriteString(" ")
+	switch {
+	case frame.To == nil:
+		builder.WriteString("<unknown>")
+	case frame.Type == "CREATE" || frame.Type == "CREATE2":
+		builder.WriteString(FormatCall(*frame.To, nil))
+	default:
+		builder.WriteString(FormatCall(*frame.To, frame.Input))
+	}
+	if frame.Value != nil && frame.Value.ToInt().Sign() > 0 {
+		fmt.Fprintf(builder, " {value: %s}", frame.Value.ToInt())
+	}
+	if frame == point {
+		fmt.Fprintf(builder, " <- reverted here: %s", frame.Reason())
+	} else if frame.Failed() {
+		fmt.Fprintf(builder, " <- %s", frame.Reason())
+	}
+	builder.WriteString("\n")
+	for _, call := range frame.Calls {
+		call.write(builder, depth+1, point)
+	}
+}
+
+// RevertPoint returns the call where the revert originated, following the failed
+// calls whose revert data was bubbled up by their caller.
+func (frame *CallFrame) RevertPoint() *CallFrame {
+	if !frame.Failed() {
+		return nil
+	}
+	for i := len(frame.Calls) - 1; i >= 0; i-- {
+		call := frame.Calls[i]
+		if call.Failed() && bytes.Equal(call.Output, frame.Output) {
+			return call.RevertPoint()
+		}
+	}
+	return frame
+}
+
+// TraceError is a failed call together with its decoded call tree.
+type TraceError struct {
+	Err   error
+	Trace *CallFrame
+}
+
+func (err *TraceError) Error() string {
+	return fmt.Sprintf("%v\n%s", err.Err, err.Trace)
+}
+
+func (err *TraceError) Unwrap() error {
+	return err.Err
+}
@@ -0,0 +1,167 @@
+package bmsutils
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ConsoleAddress is the address hardhat's console.sol sends its logs to.
+var ConsoleAddress common.Address = common.HexToAddress("0x000000000000000000636F6e736F6c652e6c6f67")
+
+var (
+	consoleABIs     map[Sig]abi.Arguments
+	consoleABIsOnce sync.Once
+)
+
+// consoleSignatures returns the signatures of the functions of console.sol.
+func consoleSignatures() []string {
+	signatures := []string{"log()", "logInt(int256)", "logUint(uint256)", "logString(string)", "logBool(bool)", "logAddress(address)", "logBytes(bytes)"}
+	for i := 1; i <= 32; i++ {
+		signatures = append(signatures, fmt.Sprintf("logBytes%d(bytes%d)", i, i))
+	}
+	for _, typ := range []string{"uint256", "int256", "string", "bool", "address", "bytes"} {
+		signatures = append(signatures, fmt.Sprintf("log(%s)", typ))
+	}
+
+	types := []string{"uint256", "string", "bool", "address"}
+	combos := [][]string{{}}
+	for params := 1; params <= 4; params++ {
+		next := make([][]string, 0, len(combos)*len(types))
+		for _, combo := range combos {
+			for _, typ := range types {
+				next = append(next, append(append([]string{}, combo...), typ))
+			}
+		}
+		combos = next
+		if params >= 2 {
+			for _, combo := range combos {
+				signatures = append(signatures, fmt.Sprintf("log(%s)", strings.Join(combo, ",")))
+			}
+		}
+	}
+	return signatures
+}
+
+func enrollConsoleABIs() {
+	consoleABIs = make(map[Sig]abi.Arguments)
+	for _, signature := range consoleSignatures() {
+		params := strings.TrimSuffix(signature[strings.Index(signature, "(")+1:], ")")
+		var args abi.Arguments
+		if params != "" {
+			for _, param := range strings.Split(params, ",") {
+				typ, err := abi.NewType(param, "", nil)
+				if err != nil {
+					panic(err) // the signatures are fixed, this should never happen
+				}
+				args = append(args, abi.Argument{Type: typ})
+			}
+		}
+		consoleABIs[Sig(crypto.Keccak256([]byte(signature))[:4])] = args
+		// console.sol before hardhat 2.10 wrote uint256 and int256 as uint and int in the
+		// signatures, which changed their selectors.
+		legacy := strings.NewReplacer("uint256", "uint", "int256", "int").Replace(signature)
+		consoleABIs[Sig(crypto.Keccak256([]byte(legacy))[:4])] = args
+	}
+}
+
+// UnpackConsoleLog decodes calldata sent to ConsoleAddress into the logged message.
+func UnpackConsoleLog(input []byte) (string, error) {
+	consoleABIsOnce.Do(enrollConsoleABIs)
+
+	if len(input) < 4 {
+		return "", fmt.Errorf("console.log calldata too short: %d bytes", len(input))
+	}
+	args, ok := consoleABIs[Sig(input[:4])]
+	if !ok {
+		return "", fmt.Errorf("unknown console.log selector %x", input[:4])
+	}
+	values, err := args.Unpack(input[4:])
+	if err != nil {
+		return "", err
+	}
+
+	strs := make([]string, len(values))
+	for i, value := range values {
+		switch value := value.(type) {
+		case common.Address:
+			strs[i] = value.Hex()
+		case []byte:
+			strs[i] = fmt.Sprintf("0x%x", value)
+		default:
+			if typ := args[i].Type; typ.T == abi.FixedBytesTy {
+				strs[i] = fmt.Sprintf("0x%x", value)
+			} else {
+				strs[i] = fmt.Sprint(value)
+			}
+		}
+	}
+	if len(values) > 1 {
+		if _, ok := values[0].(string); ok {
+			return formatConsole(strs[0], strs[1:]), nil
+		}
+	}
+	return strings.Join(strs, " "), nil
+}
+
+// formatConsole substitutes the format specifiers of the message with the arguments, like
+// console.log of hardhat does with util.format: %s, %d, %i, %f, %o, %O and %j print the
+// next argument, %c consumes it and %% is a %. The arguments left are appended, separated
+// by spaces, and specifiers without an argument are kept.
+func formatConsole(message string, args []string) string {
+	var builder strings.Builder
+	for i := 0; i < len(message); i++ {
+		if message[i] != '%' || i+1 == len(message) {
+			builder.WriteByte(message[i])
+			continue
+		}
+		switch verb := message[i+1]; verb {
+		case '%':
+			builder.WriteByte('%')
+			i++
+		case 's', 'd', 'i', 'f', 'o', 'O', 'j', 'c':
+			if len(args) == 0 {
+				builder.WriteByte('%')
+				continue
+			}
+			if verb != 'c' {
+				builder.WriteString(args[0])
+			}
+			args = args[1:]
+			i++
+		default:
+			builder.WriteByte('%')
+		}
+	}
+	for _, arg := range args {
+		builder.WriteByte(' ')
+		builder.WriteString(arg)
+	}
+	return builder.String()
+}
+
+// ConsoleLogs returns the messages logged with console.sol during the call, in order.
+func (frame *CallFrame) ConsoleLogs() []string {
+	logs := make([]string, 0)
+	frame.walk(func(call *CallFrame) {
+		if call.To != nil && *call.To == ConsoleAddress {
+			if log, err := UnpackConsoleLog(call.Input); err != nil {
+				logs = append(logs, fmt.Sprintf("<%v>", err))
+			} else {
+				logs = append(logs, log)
+			}
+		}
+	})
+	return logs
+}
+
+func (frame *CallFrame) walk(fn func(*CallFrame)) {
+	fn(frame)
+	for _, call := range frame.Calls {
+		call.walk(fn)
+	}
+}
@@ -1,6 +1,8 @@
 package bmsutils
 
 import (
+	"reflect"
+
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -16,6 +18,7 @@ func DeployContract[T any](address common.Address, tx *types.Transaction, contra
 	if err != nil {
 		return nil, nil, err
 	}
+	EnrollContract(address, contractName[T](), nil)
 	return &Contract[T]{address: address, funcs: contract}, tx, nil
 }
 
@@ -30,6 +33,7 @@ func NewContract[T any](contract *T, err error) (*Contract[T], error) {
 func (contract *Contract[T]) SetAddress(address common.Address) *Contract[T] {
 	if contract.address == (common.Address{0}) {
 		contract.address = address
+		EnrollContract(address, contractName[T](), contract.abi)
 	}
 	return contract
 }
@@ -38,6 +42,9 @@ func (contract *Contract[T]) SetABI(abi *abi.ABI) *Contract[T] {
 	if contract.abi == nil {
 		EnrollErrors(abi)
 		contract.abi = abi
+		if contract.address != (common.Address{0}) {
+			EnrollContract(contract.address, contractName[T](), abi)
+		}
 	}
 	return contract
 }
@@ -60,3 +67,8 @@ func (contract *Contract[T]) Funcs() *T {
 func (contract *Contract[T]) ABI() *abi.ABI {
 	return contract.abi
 }
+
+// contractName returns the name of the binding type, which abigen names after the contract.
+func contractName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().Name()
+}
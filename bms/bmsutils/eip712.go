@@ -0,0 +1,371 @@
+package bmsutils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/pkg/errors"
+)
+
+const eip712ABI = `[
+	{"type":"function","name":"eip712Domain","inputs":[],"outputs":[{"name":"fields","type":"bytes1"},{"name":"name","type":"string"},{"name":"version","type":"string"},{"name":"chainId","type":"uint256"},{"name":"verifyingContract","type":"address"},{"name":"salt","type":"bytes32"},{"name":"extensions","type":"uint256[]"}],"stateMutability":"view"},
+	{"type":"function","name":"nonces","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}],"stateMutability":"view"}
+]`
+
+var eip712Contract = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(eip712ABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// Domain is the EIP-712 domain of a contract. Unset fields, empty or nil, are not part of
+// the domain.
+type Domain struct {
+	Name              string
+	Version           string
+	ChainID           *big.Int
+	VerifyingContract *common.Address
+	Salt              *common.Hash
+}
+
+// ReadDomain reads the domain of the contract from its eip712Domain() (EIP-5267), e.g.
+// the one of the EIP712 contract of OpenZeppelin 4.9 and later.
+func ReadDomain(ctx context.Context, caller bind.ContractCaller, contract common.Address) (*Domain, error) {
+	output, err := call(ctx, caller, contract, "eip712Domain")
+	if err != nil {
+		return nil, err
+	}
+	fields := output[0].([1]byte)[0]
+	domain := new(Domain)
+	if fields&0x01 != 0 {
+		domain.Name = output[1].(string)
+	}
+	if fields&0x02 != 0 {
+		domain.Version = output[2].(string)
+	}
+	if fields&0x04 != 0 {
+		domain.ChainID = output[3].(*big.Int)
+	}
+	if fields&0x08 != 0 {
+		address := output[4].(common.Address)
+		domain.VerifyingContract = &address
+	}
+	if fields&0x10 != 0 {
+		salt := common.Hash(output[5].([32]byte))
+		domain.Salt = &salt
+	}
+	return domain, nil
+}
+
+func call(ctx context.Context, caller bind.ContractCaller, contract common.Address, method string, args ...interface{}) ([]interface{}, error) {
+	input, err := eip712Contract.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	output, err := caller.CallContract(ensureContext(ctx), ethereum.CallMsg{To: &contract, Data: input}, nil)
+	if err != nil {
+		return nil, errors.Wrap(ToRevert(err), method)
+	}
+	values, err := eip712Contract.Unpack(method, output)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s of %v", method, contract)
+	}
+	return values, nil
+}
+
+// NewTypedData returns the EIP-712 message of the domain, message being the values of
+// primaryType, one of types. Addresses are hex strings and integers *big.Int.
+func NewTypedData(domain *Domain, primaryType string, types apitypes.Types, message apitypes.TypedDataMessage) apitypes.TypedData {
+	data := apitypes.TypedData{
+		Types:       apitypes.Types{"EIP712Domain": domain.types()},
+		PrimaryType: primaryType,
+		Domain:      domain.typedDataDomain(),
+		Message:     message,
+	}
+	for name, fields := range types {
+		data.Types[name] = fields
+	}
+	return data
+}
+
+// TypedDataOf returns the EIP-712 message of the domain from a struct, the primary type
+// being the name of its type. Its exported fields are the ones of the message, named and
+// typed by their eip712 tag, "-" skipping the field:
+//
+//	type Mail struct {
+//		From     Person                          // from Person
+//		To       Person   `eip712:"recipient"`    // recipient Person
+//		Amount   *big.Int `eip712:"amount,uint96"` // uint256 unless typed
+//		Memo     string   `eip712:"-"`
+//	}
+//
+// Addresses, strings, bools, byte slices and arrays, integers, structs and slices of them
+// are supported.
+func TypedDataOf(domain *Domain, message interface{}) (apitypes.TypedData, error) {
+	value := reflect.Indirect(reflect.ValueOf(message))
+	if value.Kind() != reflect.Struct {
+		return apitypes.TypedData{}, fmt.Errorf("expected a struct, got %T", message)
+	}
+	types := make(apitypes.Types)
+	values, err := encodeStruct(types, value)
+	if err != nil {
+		return apitypes.TypedData{}, err
+	}
+	return NewTypedData(domain, value.Type().Name(), types, values), nil
+}
+
+var (
+	addressType = reflect.TypeOf(common.Address{})
+	bigIntType  = reflect.TypeOf(big.Int{})
+)
+
+// encodeStruct adds the type of the struct and the ones it references to types, and
+// returns its values.
+func encodeStruct(types apitypes.Types, value reflect.Value) (map[string]interface{}, error) {
+	structType := value.Type()
+	fields := make([]apitypes.Type, 0, structType.NumField())
+	values := make(map[string]interface{})
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, typeName, _ := strings.Cut(field.Tag.Get("eip712"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = string(unicode.ToLower(rune(field.Name[0]))) + field.Name[1:]
+		}
+		encoded, inferred, err := encodeValue(types, value.Field(i))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s.%s", structType.Name(), field.Name)
+		}
+		if typeName == "" {
+			typeName = inferred
+		}
+		fields = append(fields, apitypes.Type{Name: name, Type: typeName})
+		values[name] = encoded
+	}
+	types[structType.Name()] = fields
+	return values, nil
+}
+
+// encodeValue returns the value as apitypes expects it, and its EIP-712 type.
+func encodeValue(types apitypes.Types, value reflect.Value) (interface{}, string, error) {
+	valueType := value.Type()
+	switch {
+	case valueType == addressType:
+		return value.Interface().(common.Address).Hex(), "address", nil
+	case valueType == bigIntType || (valueType.Kind() == reflect.Pointer && valueType.Elem() == bigIntType):
+		number, ok := value.Interface().(*big.Int)
+		if !ok {
+			amount := value.Interface().(big.Int)
+			number = &amount
+		}
+		if number == nil {
+			number = new(big.Int)
+		}
+		return number, "uint256", nil
+	}
+
+	switch valueType.Kind() {
+	case reflect.Bool:
+		return value.Bool(), "bool", nil
+	case reflect.String:
+		return value.String(), "string", nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return new(big.Int).SetUint64(value.Uint()), fmt.Sprintf("uint%d", valueType.Bits()), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return big.NewInt(value.Int()), fmt.Sprintf("int%d", valueType.Bits()), nil
+	case reflect.Struct:
+		values, err := encodeStruct(types, value)
+		return values, valueType.Name(), err
+	case reflect.Pointer:
+		if value.IsNil() {
+			return nil, "", errors.New("nil pointer")
+		}
+		return encodeValue(types, value.Elem())
+	case reflect.Array:
+		if valueType.Elem().Kind() == reflect.Uint8 && valueType.Len() <= 32 {
+			bytes := make([]byte, valueType.Len())
+			reflect.Copy(reflect.ValueOf(bytes), value)
+			return bytes, fmt.Sprintf("bytes%d", valueType.Len()), nil
+		}
+	case reflect.Slice:
+		if valueType.Elem().Kind() == reflect.Uint8 {
+			return value.Bytes(), "bytes", nil
+		}
+		items := make([]interface{}, value.Len())
+		itemType := ""
+		for i := range items {
+			var err error
+			if items[i], itemType, err = encodeValue(types, value.Index(i)); err != nil {
+				return nil, "", err
+			}
+		}
+		if itemType == "" {
+			// 빈 슬라이스는 원소의 zero value 로 타입을 정한다.
+			var err error
+			if _, itemType, err = encodeValue(types, reflect.New(valueType.Elem()).Elem()); err != nil {
+				return nil, "", err
+			}
+		}
+		return items, itemType + "[]", nil
+	}
+	return nil, "", fmt.Errorf("unsupported type %v", valueType)
+}
+
+func (domain *Domain) types() []apitypes.Type {
+	types := make([]apitypes.Type, 0, 5)
+	if domain.Name != "" {
+		types = append(types, apitypes.Type{Name: "name", Type: "string"})
+	}
+	if domain.Version != "" {
+		types = append(types, apitypes.Type{Name: "version", Type: "string"})
+	}
+	if domain.ChainID != nil {
+		types = append(types, apitypes.Type{Name: "chainId", Type: "uint256"})
+	}
+	if domain.VerifyingContract != nil {
+		types = append(types, apitypes.Type{Name: "verifyingContract", Type: "address"})
+	}
+	if domain.Salt != nil {
+		types = append(types, apitypes.Type{Name: "salt", Type: "bytes32"})
+	}
+	return types
+}
+
+func (domain *Domain) typedDataDomain() apitypes.TypedDataDomain {
+	typed := apitypes.TypedDataDomain{Name: domain.Name, Version: domain.Version}
+	if domain.ChainID != nil {
+		typed.ChainId = (*math.HexOrDecimal256)(domain.ChainID)
+	}
+	if domain.VerifyingContract != nil {
+		typed.VerifyingContract = domain.VerifyingContract.Hex()
+	}
+	if domain.Salt != nil {
+		typed.Salt = domain.Salt.Hex()
+	}
+	return typed
+}
+
+// Signature is a signature split for the contracts taking v, r and s.
+type Signature struct {
+	// Bytes is r, s and v, v being 27 or 28.
+	Bytes []byte
+	V     uint8
+	R, S  [32]byte
+}
+
+// newSignature splits the signature of crypto.Sign, its v being 0 or 1.
+func newSignature(signature []byte) *Signature {
+	sig := &Signature{Bytes: common.CopyBytes(signature), V: signature[64] + 27}
+	sig.Bytes[64] = sig.V
+	copy(sig.R[:], signature[:32])
+	copy(sig.S[:], signature[32:64])
+	return sig
+}
+
+// TypedDataHash returns the digest of the EIP-712 message, the one signed.
+func TypedDataHash(data apitypes.TypedData) (common.Hash, error) {
+	hash, _, err := apitypes.TypedDataAndHash(data)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(hash), nil
+}
+
+// SignTypedData signs the EIP-712 message with the enrolled key of the account of opts,
+// e.g. an account of the bms wallet, see EnrollKey.
+func SignTypedData(opts *bind.TransactOpts, data apitypes.TypedData) (*Signature, error) {
+	key, err := KeyOf(opts)
+	if err != nil {
+		return nil, err
+	}
+	return SignTypedDataWithKey(key, data)
+}
+
+// SignTypedDataWithKey signs the EIP-712 message with the key.
+func SignTypedDataWithKey(key *ecdsa.PrivateKey, data apitypes.TypedData) (*Signature, error) {
+	hash, err := TypedDataHash(data)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := crypto.Sign(hash[:], key)
+	if err != nil {
+		return nil, err
+	}
+	return newSignature(signature), nil
+}
+
+// Permit is the message of ERC-2612 permit.
+type Permit struct {
+	Owner    common.Address
+	Spender  common.Address
+	Value    *big.Int
+	Nonce    *big.Int
+	Deadline *big.Int
+}
+
+// permitTypes are the types of the ERC-2612 permit.
+var permitTypes = apitypes.Types{"Permit": {
+	{Name: "owner", Type: "address"},
+	{Name: "spender", Type: "address"},
+	{Name: "value", Type: "uint256"},
+	{Name: "nonce", Type: "uint256"},
+	{Name: "deadline", Type: "uint256"},
+}}
+
+// PermitTypedData returns the ERC-2612 permit of the token of the domain. Nil amounts
+// are 0.
+func PermitTypedData(domain *Domain, permit *Permit) apitypes.TypedData {
+	uint256 := func(number *big.Int) *big.Int {
+		if number == nil {
+			return new(big.Int)
+		}
+		return number
+	}
+	return NewTypedData(domain, "Permit", permitTypes, apitypes.TypedDataMessage{
+		"owner":    permit.Owner.Hex(),
+		"spender":  permit.Spender.Hex(),
+		"value":    uint256(permit.Value),
+		"nonce":    uint256(permit.Nonce),
+		"deadline": uint256(permit.Deadline),
+	})
+}
+
+// SignPermit signs the ERC-2612 permit of the token for the owner, its domain and the
+// nonce of the owner read from the token. The token must implement eip712Domain(), see
+// PermitTypedData for the others.
+func SignPermit(ctx context.Context, caller bind.ContractCaller, owner *bind.TransactOpts, token, spender common.Address, value, deadline *big.Int) (*Signature, error) {
+	domain, err := ReadDomain(ctx, caller, token)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := call(ctx, caller, token, "nonces", owner.From)
+	if err != nil {
+		return nil, err
+	}
+	return SignTypedData(owner, PermitTypedData(domain, &Permit{
+		Owner:    owner.From,
+		Spender:  spender,
+		Value:    value,
+		Nonce:    nonce[0].(*big.Int),
+		Deadline: deadline,
+	}))
+}
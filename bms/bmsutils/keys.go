@@ -0,0 +1,41 @@
+package bmsutils
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var keys map[common.Address]*ecdsa.PrivateKey = make(map[common.Address]*ecdsa.PrivateKey)
+
+// EnrollKey registers private keys, so messages can be signed for their accounts. The
+// accounts of the bms wallet are enrolled when they are derived.
+func EnrollKey(privateKeys ...*ecdsa.PrivateKey) {
+	enrollMu.Lock()
+	defer enrollMu.Unlock()
+
+	for _, key := range privateKeys {
+		keys[crypto.PubkeyToAddress(key.PublicKey)] = key
+	}
+}
+
+// GetEnrolledKey returns the private key enrolled for address.
+func GetEnrolledKey(address common.Address) (*ecdsa.PrivateKey, bool) {
+	enrollMu.RLock()
+	defer enrollMu.RUnlock()
+
+	key, ok := keys[address]
+	return key, ok
+}
+
+// KeyOf returns the enrolled key of the account of opts, e.g. to sign messages for an
+// account of the bms wallet.
+func KeyOf(opts *bind.TransactOpts) (*ecdsa.PrivateKey, error) {
+	if key, ok := GetEnrolledKey(opts.From); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no key enrolled for %v, see EnrollKey", opts.From)
+}
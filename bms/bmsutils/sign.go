@@ -0,0 +1,140 @@
+package bmsutils
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/pkg/errors"
+)
+
+const erc1271ABI = `[{"type":"function","name":"isValidSignature","inputs":[{"name":"hash","type":"bytes32"},{"name":"signature","type":"bytes"}],"outputs":[{"name":"","type":"bytes4"}],"stateMutability":"view"}]`
+
+var erc1271 = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(erc1271ABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// erc1271MagicValue is the return of isValidSignature(bytes32,bytes) for a valid signature.
+var erc1271MagicValue = erc1271.Methods["isValidSignature"].ID
+
+// SplitSignature splits a 65 bytes signature, its v being 0, 1, 27 or 28.
+func SplitSignature(signature []byte) (*Signature, error) {
+	if len(signature) != crypto.SignatureLength {
+		return nil, fmt.Errorf("signature of %d bytes, expected %d", len(signature), crypto.SignatureLength)
+	}
+	sig := common.CopyBytes(signature)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	if sig[64] > 1 {
+		return nil, fmt.Errorf("invalid signature v %d", signature[64])
+	}
+	return newSignature(sig), nil
+}
+
+// MessageHash returns the EIP-191 hash of the message, the one personal_sign and
+// eth_sign sign and ECDSA.toEthSignedMessageHash computes.
+func MessageHash(message []byte) common.Hash {
+	return common.BytesToHash(accounts.TextHash(message))
+}
+
+// SignHash signs the hash as is with the enrolled key of the account of opts.
+func SignHash(opts *bind.TransactOpts, hash common.Hash) (*Signature, error) {
+	key, err := KeyOf(opts)
+	if err != nil {
+		return nil, err
+	}
+	return SignHashWithKey(key, hash)
+}
+
+// SignHashWithKey signs the hash as is with the key.
+func SignHashWithKey(key *ecdsa.PrivateKey, hash common.Hash) (*Signature, error) {
+	signature, err := crypto.Sign(hash[:], key)
+	if err != nil {
+		return nil, err
+	}
+	return newSignature(signature), nil
+}
+
+// SignMessage signs the message like personal_sign (EIP-191) with the enrolled key of the
+// account of opts.
+func SignMessage(opts *bind.TransactOpts, message []byte) (*Signature, error) {
+	return SignHash(opts, MessageHash(message))
+}
+
+// SignMessageWithKey signs the message like personal_sign (EIP-191) with the key.
+func SignMessageWithKey(key *ecdsa.PrivateKey, message []byte) (*Signature, error) {
+	return SignHashWithKey(key, MessageHash(message))
+}
+
+// RecoverHash returns the account that signed the hash.
+func RecoverHash(hash common.Hash, signature []byte) (common.Address, error) {
+	sig, err := SplitSignature(signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	raw := common.CopyBytes(sig.Bytes)
+	raw[64] -= 27
+	key, err := crypto.SigToPub(hash[:], raw)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*key), nil
+}
+
+// RecoverMessage returns the account that signed the message with personal_sign.
+func RecoverMessage(message []byte, signature []byte) (common.Address, error) {
+	return RecoverHash(MessageHash(message), signature)
+}
+
+// RecoverTypedData returns the account that signed the EIP-712 message.
+func RecoverTypedData(data apitypes.TypedData, signature []byte) (common.Address, error) {
+	hash, err := TypedDataHash(data)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return RecoverHash(hash, signature)
+}
+
+// IsValidSignature reports whether the signature of the hash is valid for the account,
+// like SignatureChecker of OpenZeppelin: a contract account is asked with
+// isValidSignature(bytes32,bytes) of ERC-1271, the signer of the others is recovered.
+func IsValidSignature(ctx context.Context, caller bind.ContractCaller, account common.Address, hash common.Hash, signature []byte) (bool, error) {
+	ctx = ensureContext(ctx)
+	code, err := caller.CodeAt(ctx, account, nil)
+	if err != nil {
+		return false, err
+	}
+	if len(code) == 0 {
+		signer, err := RecoverHash(hash, signature)
+		return err == nil && signer == account, nil
+	}
+
+	input, err := erc1271.Pack("isValidSignature", hash, signature)
+	if err != nil {
+		return false, err
+	}
+	output, err := caller.CallContract(ctx, ethereum.CallMsg{To: &account, Data: input}, nil)
+	if err != nil {
+		// 되돌려진 호출은 유효하지 않은 서명
+		var revert revertError
+		if errors.As(err, &revert) || strings.Contains(err.Error(), "execution reverted") {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "isValidSignature")
+	}
+	return len(output) == 32 && bytes.Equal(output[:4], erc1271MagicValue), nil
+}
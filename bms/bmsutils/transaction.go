@@ -34,7 +34,14 @@ func CreateDynamicTx(backend Backend, opts *bind.TransactOpts, to *common.Addres
 	// Estimate FeeCap
 	gasFeeCap := opts.GasFeeCap
 	if gasFeeCap == nil {
+		head, err := backend.HeaderByNumber(ensureContext(opts.Context), nil)
+		if err != nil {
+			return nil, err
+		}
 		gasFeeCap = gasTipCap
+		if head.BaseFee != nil {
+			gasFeeCap = new(big.Int).Add(gasTipCap, new(big.Int).Mul(head.BaseFee, common.Big2))
+		}
 	}
 	if gasFeeCap.Cmp(gasTipCap) < 0 {
 		return nil, fmt.Errorf("maxFeePerGas (%v) < maxPriorityFeePerGas (%v)", gasFeeCap, gasTipCap)
@@ -74,6 +81,20 @@ func SendDynamicTx(backend Backend, opts *bind.TransactOpts, to *common.Address,
 	}
 }
 
+// GasCost returns the total amount of wei paid for the gas used by the receipts.
+func GasCost(receipts ...*types.Receipt) *big.Int {
+	cost := new(big.Int)
+	for _, receipt := range receipts {
+		if receipt.EffectiveGasPrice != nil {
+			cost.Add(cost, new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice))
+		}
+		if receipt.BlobGasPrice != nil {
+			cost.Add(cost, new(big.Int).Mul(new(big.Int).SetUint64(receipt.BlobGasUsed), receipt.BlobGasPrice))
+		}
+	}
+	return cost
+}
+
 // ensureContext is a helper method to ensure a context is not nil, even if the
 // user specified it as such.
 func ensureContext(ctx context.Context) context.Context {
@@ -0,0 +1,148 @@
+package bmsutils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	methodABIs map[Sig]abi.Method                   = make(map[Sig]abi.Method)
+	contracts  map[common.Address]*EnrolledContract = make(map[common.Address]*EnrolledContract)
+)
+
+// EnrolledContract is a deployed contract known by its name and ABI.
+type EnrolledContract struct {
+	Name string
+	ABI  *abi.ABI
+}
+
+// EnrollContract registers the name and ABI of the contract deployed at address.
+// Empty name or nil ABI keeps the previously enrolled value.
+func EnrollContract(address common.Address, name string, aBI *abi.ABI) {
+	enrollMu.Lock()
+	defer enrollMu.Unlock()
+
+	contract, ok := contracts[address]
+	if !ok {
+		contract = new(EnrolledContract)
+		contracts[address] = contract
+	}
+	if name != "" {
+		contract.Name = name
+	}
+	if aBI != nil {
+		contract.ABI = aBI
+		enrollMethods(aBI)
+	}
+}
+
+// GetEnrolledContract returns the contract enrolled at address.
+func GetEnrolledContract(address common.Address) (EnrolledContract, bool) {
+	enrollMu.RLock()
+	defer enrollMu.RUnlock()
+
+	contract, ok := contracts[address]
+	if !ok {
+		return EnrolledContract{}, false
+	}
+	return *contract, true
+}
+
+// GetEnrolledMethod returns the enrolled method called by sig on the contract at address.
+// The ABI of the contract at address takes precedence over the other enrolled methods.
+func GetEnrolledMethod(address common.Address, sig Sig) (abi.Method, bool) {
+	enrollMu.RLock()
+	defer enrollMu.RUnlock()
+
+	if contract, ok := contracts[address]; ok && contract.ABI != nil {
+		if method, ok := methodBySig(contract.ABI, sig); ok {
+			return method, true
+		}
+	}
+	method, ok := methodABIs[sig]
+	return method, ok
+}
+
+// enrollMethods expects the enroll lock to be held.
+func enrollMethods(aBI *abi.ABI) {
+	for _, method := range aBI.Methods {
+		sig := Sig(method.ID[:4])
+		if _, ok := methodABIs[sig]; !ok {
+			methodABIs[sig] = method
+		}
+	}
+}
+
+// UnpackCall decodes the calldata sent to address with the enrolled ABIs.
+func UnpackCall(address common.Address, input []byte) (abi.Method, []interface{}, error) {
+	if len(input) < 4 {
+		return abi.Method{}, nil, fmt.Errorf("calldata too short: %d bytes", len(input))
+	}
+
+	method, ok := GetEnrolledMethod(address, Sig(input[:4]))
+	if !ok {
+		return abi.Method{}, nil, fmt.Errorf("unknown method %x", input[:4])
+	}
+	args, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return method, nil, err
+	}
+	return method, args, nil
+}
+
+// FormatCall returns a readable form of a call such as "Token.transfer(0x.., 100)".
+// Unknown contracts are written by their address and unknown methods by their selector.
+func FormatCall(address common.Address, input []byte) string {
+	contract := address.Hex()
+	if enrolled, ok := GetEnrolledContract(address); ok && enrolled.Name != "" {
+		contract = enrolled.Name
+	}
+	if len(input) == 0 {
+		return contract
+	}
+
+	method, args, err := UnpackCall(address, input)
+	if err != nil {
+		if method.Name != "" {
+			return fmt.Sprintf("%s.%s(<%v>)", contract, method.Name, err)
+		}
+		if len(input) < 4 {
+			return fmt.Sprintf("%s.<0x%x>", contract, input)
+		}
+		return fmt.Sprintf("%s.<0x%x>(...)", contract, input[:4])
+	}
+
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = formatArg(arg)
+	}
+	return fmt.Sprintf("%s.%s(%s)", contract, method.Name, strings.Join(strs, ", "))
+}
+
+func formatArg(arg interface{}) string {
+	switch arg := arg.(type) {
+	case common.Address:
+		if enrolled, ok := GetEnrolledContract(arg); ok && enrolled.Name != "" {
+			return fmt.Sprintf("%s[%s]", enrolled.Name, arg.Hex())
+		}
+		return arg.Hex()
+	case []byte:
+		return fmt.Sprintf("0x%x", arg)
+	case string:
+		return fmt.Sprintf("%q", arg)
+	default:
+		return fmt.Sprint(arg)
+	}
+}
+
+func methodBySig(aBI *abi.ABI, sig Sig) (abi.Method, bool) {
+	for _, method := range aBI.Methods {
+		if Sig(method.ID[:4]) == sig {
+			return method, true
+		}
+	}
+	return abi.Method{}, false
+}
@@ -2,6 +2,7 @@ package bmsutils
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -11,9 +12,15 @@ type Sig [4]byte
 
 var (
 	errorABIs map[Sig]abi.Error = make(map[Sig]abi.Error)
+	enrollMu  sync.RWMutex
 )
 
+// EnrollErrors registers the custom errors of the ABIs for ToRevert, and their methods
+// for decoding call traces.
 func EnrollErrors(aBIs ...*abi.ABI) {
+	enrollMu.Lock()
+	defer enrollMu.Unlock()
+
 	for _, aBI := range aBIs {
 		for _, err := range aBI.Errors {
 			sig := Sig(err.ID[:4])
@@ -21,6 +28,7 @@ func EnrollErrors(aBIs ...*abi.ABI) {
 				errorABIs[sig] = err
 			}
 		}
+		enrollMethods(aBI)
 	}
 }
 
@@ -53,18 +61,33 @@ func ToRevert(input error) error {
 	}
 
 	data, err := hexutil.Decode(hexBytes)
-	if err != nil || len(data) < 4 {
+	if err != nil {
 		return input
 	}
 
+	if err := UnpackRevert(data); err != nil {
+		return err
+	}
+	return input
+}
+
+// UnpackRevert decodes revert data with the enrolled errors.
+// It returns nil if the data does not match any of them.
+func UnpackRevert(data []byte) error {
+	if len(data) < 4 {
+		return nil
+	}
+
+	enrollMu.RLock()
 	aBI, ok := errorABIs[Sig(data[:4])]
+	enrollMu.RUnlock()
 	if !ok {
-		return input
+		return nil
 	}
 
 	args, err := aBI.Unpack(data)
 	if err != nil {
-		return input
+		return nil
 	}
 
 	return &RevertError{aBI, args}
@@ -0,0 +1,207 @@
+package bms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// DefaultDeploymentsDir is where deployments are recorded when DeploymentsDirEnv is unset.
+const DefaultDeploymentsDir string = "deployments"
+
+// Deployment is the record of a deployed contract, kept in
+// <deployments>/<network>/<name>.json.
+type Deployment struct {
+	Name         string          `json:"name"`
+	Address      common.Address  `json:"address"`
+	TxHash       common.Hash     `json:"txHash"`
+	Block        uint64          `json:"block"`
+	Args         []interface{}   `json:"args"`
+	ArgsData     hexutil.Bytes   `json:"argsData"`
+	BytecodeHash common.Hash     `json:"bytecodeHash"`
+	ABI          json.RawMessage `json:"abi,omitempty"`
+}
+
+// DeployMain runs the deploy function of a script started by bms deploy, see RunMain.
+// Use it from main:
+//
+//	func main() {
+//		bms.DeployMain(func(deployer *bms.Deployer) error {
+//			_, err := deployer.Deploy("Token", token.TokenMetaData, "Token", "TKN")
+//			return err
+//		})
+//	}
+func DeployMain(deploy func(deployer *Deployer) error) {
+	RunMain(func(env *Env) error {
+		return deploy(env.Deployer)
+	})
+}
+
+// Deployer deploys contracts to a network and records them, skipping the contracts whose
+// recorded bytecode and constructor arguments are unchanged.
+type Deployer struct {
+	Network string
+	Backend bmsutils.Backend
+	Opts    *bind.TransactOpts
+	// Confirmations is the number of blocks, the one of the deployment included, Deploy
+	// waits for. 0 and 1 wait for the deployment to be mined.
+	Confirmations uint64
+	dir           string
+}
+
+// NewDeployer records the deployments made with opts on backend in dir/network.
+func NewDeployer(network string, backend bmsutils.Backend, opts *bind.TransactOpts, dir string) *Deployer {
+	return &Deployer{Network: network, Backend: backend, Opts: opts, dir: dir}
+}
+
+// NewDeployerFromEnv returns the deployer of NewEnv.
+func NewDeployerFromEnv(ctx context.Context) (*Deployer, error) {
+	env, err := NewEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return env.Deployer, nil
+}
+
+// Deploy deploys the contract of the abigen metadata under name, unless the recorded
+// deployment of name has the same bytecode and arguments and is still on the chain.
+func (deployer *Deployer) Deploy(name string, metaData *bind.MetaData, args ...interface{}) (*Deployment, error) {
+	aBI, err := metaData.GetAbi()
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	bytecode, err := hexutil.Decode(ensureHexPrefix(metaData.Bin))
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s bytecode", name)
+	}
+	argsData, err := aBI.Pack("", args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s constructor", name)
+	}
+	bytecodeHash := crypto.Keccak256Hash(bytecode)
+
+	ctx := deployer.context()
+	if recorded, err := deployer.Deployment(name); err == nil {
+		if recorded.BytecodeHash == bytecodeHash && bytes.Equal(recorded.ArgsData, argsData) {
+			code, err := deployer.Backend.CodeAt(ctx, recorded.Address, nil)
+			if err != nil {
+				return nil, err
+			}
+			if len(code) != 0 {
+				return recorded, nil
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	tx, err := bmsutils.SendDynamicTx(deployer.Backend, deployer.Opts, nil, append(bytecode, argsData...))
+	if err != nil {
+		return nil, errors.Wrapf(err, "deploy %s", name)
+	}
+	receipt, err := bind.WaitMined(ctx, deployer.Backend, tx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "deploy %s", name)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("deploy %s: transaction %s failed", name, tx.Hash().Hex())
+	}
+	if err := deployer.confirm(ctx, receipt.BlockNumber.Uint64()); err != nil {
+		return nil, errors.Wrapf(err, "deploy %s", name)
+	}
+
+	deployment := &Deployment{
+		Name:         name,
+		Address:      crypto.CreateAddress(deployer.Opts.From, tx.Nonce()),
+		TxHash:       tx.Hash(),
+		Block:        receipt.BlockNumber.Uint64(),
+		Args:         args,
+		ArgsData:     argsData,
+		BytecodeHash: bytecodeHash,
+		ABI:          json.RawMessage(metaData.ABI),
+	}
+	if deployment.Args == nil {
+		deployment.Args = make([]interface{}, 0)
+	}
+	bmsutils.EnrollContract(deployment.Address, name, aBI)
+	return deployment, deployer.record(deployment)
+}
+
+// Deployment reads the recorded deployment of name.
+func (deployer *Deployer) Deployment(name string) (*Deployment, error) {
+	bytes, err := os.ReadFile(deployer.path(name))
+	if err != nil {
+		return nil, err
+	}
+	deployment := new(Deployment)
+	if err := json.Unmarshal(bytes, deployment); err != nil {
+		return nil, errors.Wrap(err, deployer.path(name))
+	}
+	return deployment, nil
+}
+
+func (deployer *Deployer) record(deployment *Deployment) error {
+	path := deployer.path(deployment.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	bytes, err := json.MarshalIndent(deployment, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(bytes, '\n'), 0644)
+}
+
+// confirm waits for the block to have the confirmations of the deployer.
+func (deployer *Deployer) confirm(ctx context.Context, block uint64) error {
+	if deployer.Confirmations <= 1 {
+		return nil
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		head, err := deployer.Backend.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if head.Number.Uint64()+1 >= block+deployer.Confirmations {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (deployer *Deployer) path(name string) string {
+	return filepath.Join(deployer.dir, deployer.Network, name+".json")
+}
+
+func (deployer *Deployer) context() context.Context {
+	if deployer.Opts.Context != nil {
+		return deployer.Opts.Context
+	}
+	return context.Background()
+}
+
+func ensureHexPrefix(s string) string {
+	if !strings.HasPrefix(s, "0x") {
+		return "0x" + s
+	}
+	return s
+}
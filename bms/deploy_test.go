@@ -0,0 +1,58 @@
+package bms_test
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeployer(t *testing.T) {
+	backend := bms.NewBacked(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	runtime := program(op(vm.STOP))
+	metaData := &bind.MetaData{
+		ABI: `[{"type":"constructor","inputs":[{"name":"x","type":"uint256"}],"stateMutability":"nonpayable"}]`,
+		Bin: hexutil.Encode(deployCode(runtime)),
+	}
+	deployer := bms.NewDeployer("test", backend, backend.Owner, dir)
+
+	deployment, err := deployer.Deploy("Stop", metaData, big.NewInt(1))
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, "test", "Stop.json"))
+	code, err := backend.CodeAt(ctx, deployment.Address, nil)
+	require.NoError(t, err)
+	require.Equal(t, runtime, code)
+
+	recorded, err := deployer.Deployment("Stop")
+	require.NoError(t, err)
+	require.Equal(t, deployment.Address, recorded.Address)
+	require.Equal(t, deployment.TxHash, recorded.TxHash)
+	require.Equal(t, deployment.Block, recorded.Block)
+
+	// unchanged bytecode and arguments are not deployed again
+	number, err := backend.BlockNumber(ctx)
+	require.NoError(t, err)
+	again, err := deployer.Deploy("Stop", metaData, big.NewInt(1))
+	require.NoError(t, err)
+	require.Equal(t, deployment.Address, again.Address)
+	after, err := backend.BlockNumber(ctx)
+	require.NoError(t, err)
+	require.Equal(t, number, after)
+
+	// changed arguments are
+	changed, err := deployer.Deploy("Stop", metaData, big.NewInt(2))
+	require.NoError(t, err)
+	require.NotEqual(t, deployment.Address, changed.Address)
+	recorded, err = deployer.Deployment("Stop")
+	require.NoError(t, err)
+	require.Equal(t, changed.Address, recorded.Address)
+}
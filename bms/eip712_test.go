@@ -0,0 +1,125 @@
+package bms_test
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+type Person struct {
+	Name   string
+	Wallet common.Address
+}
+
+type Mail struct {
+	From     Person
+	To       Person
+	Contents string
+	Note     string `eip712:"-"`
+}
+
+func TestTypedData(t *testing.T) {
+	// EIP-712 의 예제 메시지와 서명
+	contract := common.HexToAddress("0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC")
+	domain := &bmsutils.Domain{Name: "Ether Mail", Version: "1", ChainID: big.NewInt(1), VerifyingContract: &contract}
+	data, err := bmsutils.TypedDataOf(domain, &Mail{
+		From:     Person{Name: "Cow", Wallet: common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826")},
+		To:       Person{Name: "Bob", Wallet: common.HexToAddress("0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB")},
+		Contents: "Hello, Bob!",
+		Note:     "not signed",
+	})
+	require.NoError(t, err)
+	hash, err := bmsutils.TypedDataHash(data)
+	require.NoError(t, err)
+	require.Equal(t, common.HexToHash("0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"), hash)
+
+	signature, err := bmsutils.SignTypedDataWithKey(crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("cow"))), data)
+	require.NoError(t, err)
+	require.Equal(t, uint8(28), signature.V)
+	require.Equal(t, common.HexToHash("0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d"), common.Hash(signature.R))
+	require.Equal(t, common.HexToHash("0x07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562"), common.Hash(signature.S))
+	require.Len(t, signature.Bytes, 65)
+	require.Equal(t, signature.V, signature.Bytes[64])
+
+	// 지갑 계정은 TransactOpts 로 서명한다.
+	eoa := bms.GetTEoa(t)
+	signature, err = bmsutils.SignTypedData(eoa, data)
+	require.NoError(t, err)
+	require.Equal(t, eoa.From, recoverSigner(t, hash, signature))
+}
+
+// recoverSigner returns the signer of the hash.
+func recoverSigner(t *testing.T, hash common.Hash, signature *bmsutils.Signature) common.Address {
+	sig := append(append(signature.R[:], signature.S[:]...), signature.V-27)
+	key, err := crypto.SigToPub(hash[:], sig)
+	require.NoError(t, err)
+	return crypto.PubkeyToAddress(*key)
+}
+
+const permitTokenABI = `[{"type":"function","name":"eip712Domain","inputs":[],"outputs":[{"name":"fields","type":"bytes1"},{"name":"name","type":"string"},{"name":"version","type":"string"},{"name":"chainId","type":"uint256"},{"name":"verifyingContract","type":"address"},{"name":"salt","type":"bytes32"},{"name":"extensions","type":"uint256[]"}],"stateMutability":"view"}]`
+
+// permitTokenCode answers nonces(address) with 5 and any other call with the domain.
+func permitTokenCode(domain []byte) []byte {
+	nonces := crypto.Keccak256([]byte("nonces(address)"))[:4]
+	size := []byte{byte(len(domain) >> 8), byte(len(domain))}
+	head := func(jump, offset byte) []byte {
+		return program(
+			push([]byte{0}), op(vm.CALLDATALOAD), push([]byte{0xe0}), op(vm.SHR), push(nonces), op(vm.EQ), push([]byte{jump}), op(vm.JUMPI),
+			push(size), push([]byte{offset}), push([]byte{0}), op(vm.CODECOPY), push(size), push([]byte{0}), op(vm.RETURN),
+		)
+	}
+	jump := byte(len(head(0, 0)))
+	tail := program(op(vm.JUMPDEST), push([]byte{5}), push([]byte{0}), op(vm.MSTORE), push([]byte{0x20}), push([]byte{0}), op(vm.RETURN))
+	offset := jump + byte(len(tail))
+	return program(head(jump, offset), tail, domain)
+}
+
+func TestPermit(t *testing.T) {
+	backend := bms.NewBacked(t)
+	ctx := context.Background()
+
+	token := common.HexToAddress("0x000000000000000000000000000000000000bEEF")
+	aBI, err := abi.JSON(strings.NewReader(permitTokenABI))
+	require.NoError(t, err)
+	domain, err := aBI.Methods["eip712Domain"].Outputs.Pack([1]byte{0x0f}, "Token", "1", bms.ChainID, token, [32]byte{}, []*big.Int{})
+	require.NoError(t, err)
+	require.NoError(t, backend.SetCode(token, permitTokenCode(domain)))
+
+	read, err := bmsutils.ReadDomain(ctx, backend, token)
+	require.NoError(t, err)
+	require.Equal(t, &bmsutils.Domain{Name: "Token", Version: "1", ChainID: bms.ChainID, VerifyingContract: &token}, read)
+
+	owner, spender := bms.GetTEoa(t), bms.GetTEoa(t)
+	value, deadline := bmsutils.ToWei(1), big.NewInt(1<<40)
+	signature, err := bmsutils.SignPermit(ctx, backend, owner, token, spender.From, value, deadline)
+	require.NoError(t, err)
+
+	// ERC20Permit 과 같은 방식으로 digest 계산
+	domainSeparator := crypto.Keccak256(
+		crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)")),
+		crypto.Keccak256([]byte("Token")), crypto.Keccak256([]byte("1")),
+		common.LeftPadBytes(bms.ChainID.Bytes(), 32), common.LeftPadBytes(token.Bytes(), 32),
+	)
+	structHash := crypto.Keccak256(
+		crypto.Keccak256([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)")),
+		common.LeftPadBytes(owner.From.Bytes(), 32), common.LeftPadBytes(spender.From.Bytes(), 32),
+		common.LeftPadBytes(value.Bytes(), 32), common.LeftPadBytes([]byte{5}, 32), common.LeftPadBytes(deadline.Bytes(), 32),
+	)
+	digest := common.BytesToHash(crypto.Keccak256([]byte("\x19\x01"), domainSeparator, structHash))
+	require.Equal(t, owner.From, recoverSigner(t, digest, signature))
+
+	// the permit is the one TypedDataOf derives from the struct
+	permit := &bmsutils.Permit{Owner: owner.From, Spender: spender.From, Value: value, Nonce: big.NewInt(5), Deadline: deadline}
+	data, err := bmsutils.TypedDataOf(read, permit)
+	require.NoError(t, err)
+	require.Equal(t, data, bmsutils.PermitTypedData(read, permit))
+}
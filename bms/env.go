@@ -0,0 +1,153 @@
+package bms
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	// NetworkEnv names the network the script runs against, set by bms run and bms deploy.
+	NetworkEnv string = "BMS_NETWORK"
+	// RPCURLEnv overrides the JSON-RPC endpoint of the network.
+	RPCURLEnv string = "BMS_RPC_URL"
+	// DeploymentsDirEnv is the directory deployments are recorded in.
+	DeploymentsDirEnv string = "BMS_DEPLOYMENTS"
+
+	DefaultNetwork string = "localhost"
+	DefaultRPCURL  string = "http://127.0.0.1:8545"
+
+	// localAccounts is the number of wallet accounts bms node funds by default.
+	localAccounts uint32 = 10
+)
+
+// Env is what a script started by bms run gets: a client of the network, its signers
+// and the deployments recorded on it.
+type Env struct {
+	Network *Network
+	ChainID *big.Int
+	Client  *ethclient.Client
+	// Signers are the accounts of the network, the one of its profile first. On the
+	// unconfigured localhost network, they are the accounts bms node funds.
+	Signers []*bind.TransactOpts
+	// Deployer deploys with the first signer and reads the recorded deployments.
+	Deployer *Deployer
+}
+
+// RunMain runs the function of a script started by bms run, reporting its error on exit.
+// Use it from main:
+//
+//	func main() {
+//		bms.RunMain(func(env *bms.Env) error {
+//			token, err := bms.Deployed(env, "Token", abis.NewToken)
+//			...
+//		})
+//	}
+func RunMain(run func(env *Env) error) {
+	env, err := NewEnv(context.Background())
+	if err == nil {
+		defer env.Client.Close()
+		err = run(env)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// NewEnv connects to the network set by bms run and bms deploy (see NetworkEnv), with
+// its profile in the project config (see ConfigFileEnv), a local bms node by default.
+func NewEnv(ctx context.Context) (*Env, error) {
+	name, url, dir, file := os.Getenv(NetworkEnv), os.Getenv(RPCURLEnv), os.Getenv(DeploymentsDirEnv), os.Getenv(ConfigFileEnv)
+	if name == "" {
+		name = DefaultNetwork
+	}
+	if dir == "" {
+		dir = DefaultDeploymentsDir
+	}
+	if file == "" {
+		file = DefaultConfigFile
+	}
+
+	conf, err := ReadConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	network, err := conf.Network(name)
+	if err != nil {
+		return nil, err
+	}
+	if url != "" {
+		copied := *network
+		copied.URL = url
+		network = &copied
+	}
+
+	client, chainID, err := network.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signers, err := network.signers(chainID)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	for _, signer := range signers {
+		signer.Context = ctx
+	}
+
+	deployer := NewDeployer(name, client, signers[0], dir)
+	deployer.Confirmations = network.Confirmations
+	return &Env{
+		Network:  network,
+		ChainID:  chainID,
+		Client:   client,
+		Signers:  signers,
+		Deployer: deployer,
+	}, nil
+}
+
+// signers returns the signer of the profile, followed by the other accounts bms node
+// funds when the network is the local one.
+func (network *Network) signers(chainID *big.Int) ([]*bind.TransactOpts, error) {
+	opts, err := network.TransactOpts(chainID)
+	if err != nil {
+		return nil, err
+	}
+	signers := []*bind.TransactOpts{opts}
+	if network.local {
+		for i := uint32(1); i < localAccounts; i++ {
+			opts, err := GetEoaAt(chainID, i)
+			if err != nil {
+				return nil, err
+			}
+			signers = append(signers, opts)
+		}
+	}
+	return signers, nil
+}
+
+// Signer returns the first signer of the network.
+func (env *Env) Signer() *bind.TransactOpts {
+	return env.Signers[0]
+}
+
+// Deployment reads the deployment recorded under name on the network.
+func (env *Env) Deployment(name string) (*Deployment, error) {
+	return env.Deployer.Deployment(name)
+}
+
+// Deployed binds the contract recorded under name with its abigen constructor, e.g.
+// bms.Deployed(env, "Token", abis.NewToken).
+func Deployed[T any](env *Env, name string, newContract func(common.Address, bind.ContractBackend) (*T, error)) (*T, error) {
+	deployment, err := env.Deployment(name)
+	if err != nil {
+		return nil, err
+	}
+	return newContract(deployment.Address, env.Client)
+}
@@ -0,0 +1,46 @@
+package bms_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnv(t *testing.T) {
+	backend := bms.NewBacked(t, bms.WithHTTP("127.0.0.1", 0))
+	dir := t.TempDir()
+	t.Setenv(bms.NetworkEnv, bms.DefaultNetwork)
+	t.Setenv(bms.RPCURLEnv, backend.HTTPEndpoint())
+	t.Setenv(bms.DeploymentsDirEnv, filepath.Join(dir, "deployments"))
+	t.Setenv(bms.ConfigFileEnv, filepath.Join(dir, "bms.json"))
+
+	env, err := bms.NewEnv(context.Background())
+	require.NoError(t, err)
+	defer env.Client.Close()
+	require.Equal(t, bms.ChainID, env.ChainID)
+	require.Len(t, env.Signers, 10)
+	require.Equal(t, backend.Owner.From, env.Signer().From)
+
+	metaData := &bind.MetaData{ABI: `[]`, Bin: hexutil.Encode(deployCode(program(op(vm.STOP))))}
+	deployment, err := env.Deployer.Deploy("Stop", metaData)
+	require.NoError(t, err)
+
+	type stop struct{ address common.Address }
+	contract, err := bms.Deployed(env, "Stop", func(address common.Address, _ bind.ContractBackend) (*stop, error) {
+		return &stop{address}, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, deployment.Address, contract.address)
+
+	_, err = bms.Deployed(env, "Missing", func(address common.Address, _ bind.ContractBackend) (*stop, error) {
+		return &stop{address}, nil
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package bms
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+// DefaultEoaBalance is the balance of the accounts of Eoas, 10000 ether like hardhat.
+var DefaultEoaBalance *big.Int = bmsutils.ToWei(10000)
+
+// balanceProbe is the value the candidate slots of a token balance are overridden with.
+var balanceProbe = common.HexToHash("0xba1a9ce0ba1a9ce0ba1a9ce0")
+
+// ERC20Balance is a token balance NewFundedEoa gives the account.
+type ERC20Balance struct {
+	Token  common.Address
+	Amount *big.Int
+}
+
+// NewFundedEoa returns the next account of the test (see GetTEoa) with the balance of
+// amount and the token balances, see SetERC20Balance. Every balance mines a block.
+func (ec *Backend) NewFundedEoa(t *testing.T, amount *big.Int, tokens ...ERC20Balance) *bind.TransactOpts {
+	eoa := GetTEoa(t)
+	require.NoError(t, ec.SetBalance(eoa.From, amount))
+	for _, token := range tokens {
+		require.NoError(t, ec.SetERC20Balance(token.Token, eoa.From, token.Amount))
+	}
+	return eoa
+}
+
+// Eoas returns the next accounts of the backend, the accounts of the wallet following the
+// owner, with DefaultEoaBalance. Every backend starts from the same account, so its
+// accounts do not depend on the other backends. The balances are set in a single block.
+func (ec *Backend) Eoas(count int) ([]*bind.TransactOpts, error) {
+	ec.eoaLock.Lock()
+	first := ec.eoaCount + 1
+	ec.eoaCount += uint32(count)
+	ec.eoaLock.Unlock()
+
+	opts := make([]*bind.TransactOpts, count)
+	addresses := make([]common.Address, count)
+	for i := range opts {
+		var err error
+		if opts[i], err = GetEoaAt(ChainID, first+uint32(i)); err != nil {
+			return nil, err
+		}
+		addresses[i] = opts[i].From
+	}
+	if err := ec.setBalances(addresses, DefaultEoaBalance); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// SetERC20Balance sets the balance of the account in the token, writing the storage slot
+// its balanceOf reads, like forge's deal. The total supply is not changed. Writing the
+// slot mines a block, see SetStorageAt.
+func (ec *Backend) SetERC20Balance(token, account common.Address, amount *big.Int) error {
+	if amount == nil || amount.Sign() < 0 || amount.BitLen() > 256 {
+		return fmt.Errorf("invalid amount %v", amount)
+	}
+	ctx := context.Background()
+	call := ethereum.CallMsg{
+		From: ec.Owner.From,
+		To:   &token,
+		Data: append(crypto.Keccak256([]byte("balanceOf(address)"))[:4], common.LeftPadBytes(account.Bytes(), 32)...),
+	}
+	if err := ec.forkLoad(ctx, call); err != nil {
+		return err
+	}
+
+	// 1. balanceOf 가 읽는 슬롯 수집
+	var prestate map[common.Address]struct {
+		Storage map[common.Hash]common.Hash `json:"storage"`
+	}
+	if err := ec.rpc.CallContext(ctx, &prestate, "debug_traceCall", toCallArg(call), "latest", prestateTracer); err != nil {
+		return err
+	}
+	slots := make([]common.Hash, 0, len(prestate[token].Storage))
+	for slot := range prestate[token].Storage {
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return bytes.Compare(slots[i][:], slots[j][:]) < 0 })
+
+	// 2. 슬롯을 바꿔서 호출했을 때 잔액이 바뀌는 슬롯에 기록
+	for _, slot := range slots {
+		overrides := map[common.Address]interface{}{
+			token: map[string]interface{}{"stateDiff": map[common.Hash]common.Hash{slot: balanceProbe}},
+		}
+		var output hexutil.Bytes
+		if err := ec.rpc.CallContext(ctx, &output, "eth_call", toCallArg(call), "latest", overrides); err != nil {
+			return err
+		}
+		if bytes.Equal(output, balanceProbe[:]) {
+			return ec.SetStorageAt(token, slot, common.BigToHash(amount))
+		}
+	}
+	return fmt.Errorf("no storage slot of %v holds the balance of %v", token, account)
+}
@@ -0,0 +1,67 @@
+package bms_test
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/require"
+)
+
+const tokenABI = `[{"type":"function","name":"balanceOf","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}],"stateMutability":"view"}]`
+
+// tokenCode answers balanceOf from the mapping at slot 3, reading slot 0 first.
+func tokenCode() []byte {
+	return program(
+		push([]byte{0}), op(vm.SLOAD), op(vm.POP),
+		push([]byte{4}), op(vm.CALLDATALOAD), push([]byte{0}), op(vm.MSTORE),
+		push([]byte{3}), push([]byte{0x20}), op(vm.MSTORE),
+		push([]byte{0x40}), push([]byte{0}), op(vm.KECCAK256), op(vm.SLOAD),
+		push([]byte{0}), op(vm.MSTORE), push([]byte{0x20}), push([]byte{0}), op(vm.RETURN),
+	)
+}
+
+func TestFundedEoas(t *testing.T) {
+	backend := bms.NewBacked(t)
+	ctx := context.Background()
+
+	token, err := abi.JSON(strings.NewReader(tokenABI))
+	require.NoError(t, err)
+	address, _, contract, err := bind.DeployContract(backend.Owner, token, deployCode(tokenCode()), backend)
+	require.NoError(t, err)
+
+	eoa := backend.NewFundedEoa(t, bmsutils.ToWei(5), bms.ERC20Balance{Token: address, Amount: big.NewInt(1000)})
+	balance, err := backend.BalanceAt(ctx, eoa.From, nil)
+	require.NoError(t, err)
+	require.Equal(t, bmsutils.ToWei(5), balance)
+	var out []interface{}
+	require.NoError(t, contract.Call(nil, &out, "balanceOf", eoa.From))
+	require.Equal(t, big.NewInt(1000), out[0])
+
+	// 잔액이 있으므로 바로 전송할 수 있다.
+	txpool := bmsutils.NewTxPool(backend)
+	eoa.Value = bmsutils.ToWei(1)
+	require.NoError(t, txpool.Exec(bmsutils.SendDynamicTx(backend, eoa, &backend.Owner.From, []byte{})))
+	require.NoError(t, txpool.AllReceiptStatusSuccessful(ctx))
+
+	eoas, err := backend.Eoas(2)
+	require.NoError(t, err)
+	next, err := backend.Eoas(1)
+	require.NoError(t, err)
+	for i, eoa := range append(eoas, next...) {
+		expected, err := bms.GetEoaAt(bms.ChainID, uint32(i+1))
+		require.NoError(t, err)
+		require.Equal(t, expected.From, eoa.From)
+		balance, err := backend.BalanceAt(ctx, eoa.From, nil)
+		require.NoError(t, err)
+		require.Equal(t, bms.DefaultEoaBalance, balance)
+	}
+
+	require.Error(t, backend.SetERC20Balance(backend.Owner.From, eoa.From, big.NewInt(1)))
+}
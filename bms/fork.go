@@ -0,0 +1,597 @@
+package bms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/holiman/uint256"
+	"github.com/pkg/errors"
+)
+
+var prestateTracer = map[string]interface{}{"tracer": "prestateTracer"}
+
+const (
+	// forkLoadRounds bounds the number of times a call is traced to find the remote
+	// state it touches.
+	forkLoadRounds = 64
+	// forkBatchSize bounds the number of requests sent to the remote node at once.
+	forkBatchSize = 100
+)
+
+// defaultForkCacheDir returns the directory the remote state of forks is cached in by
+// default, or "" if there is no cache directory for the user.
+func defaultForkCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "bms", "fork")
+}
+
+// fork copies the state of a remote chain at a block into a backend, lazily.
+//
+// Before a call or a transaction is executed, it is traced with the prestate tracer
+// to find the accounts and storage slots it touches. Those the local state does not
+// hold yet are fetched from the remote node, and the call is traced again with them as
+// state overrides until it touches nothing new. Reads and calls only use the overrides,
+// transactions and the setters of the state write them to the local state first.
+//
+// The accounts of the genesis, and those created by local transactions, are local:
+// nothing is fetched for them.
+type fork struct {
+	remote   *rpc.Client
+	header   *types.Header
+	cacheDir string
+
+	// lock is taken before commitLock of the backend.
+	lock   sync.Mutex
+	cache  map[common.Address]*forkAccount
+	loaded map[common.Address]*loadedAccount
+}
+
+// forkAccount is the state of a remote account, with the storage slots fetched so far.
+type forkAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   hexutil.Uint64              `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+func (account *forkAccount) empty() bool {
+	return account.Balance.ToInt().Sign() == 0 && account.Nonce == 0 && len(account.Code) == 0
+}
+
+// loadedAccount tracks what the local state holds of an account, by the number of the
+// block it was loaded at, so that rewinding the chain forgets what was loaded after.
+type loadedAccount struct {
+	number uint64
+	local  bool
+	slots  map[common.Hash]uint64
+}
+
+// newFork connects to the remote node and pins the block to fork from, the latest one
+// if number is nil. The remote state is cached in cacheDir, per chain and block.
+func newFork(ctx context.Context, url string, number *big.Int, cacheDir string) (*fork, error) {
+	remote, err := rpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, errors.Wrap(err, "fork")
+	}
+	client := ethclient.NewClient(remote)
+	header, err := client.HeaderByNumber(ctx, number)
+	if err != nil {
+		remote.Close()
+		return nil, errors.Wrap(err, "fork: block")
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		remote.Close()
+		return nil, errors.Wrap(err, "fork: chain id")
+	}
+
+	f := &fork{
+		remote: remote,
+		header: header,
+		cache:  make(map[common.Address]*forkAccount),
+		loaded: make(map[common.Address]*loadedAccount),
+	}
+	if cacheDir != "" {
+		f.cacheDir = filepath.Join(cacheDir, chainID.String(), header.Number.String())
+		if err := os.MkdirAll(f.cacheDir, 0755); err != nil {
+			remote.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// setLocal marks the accounts as local, so their state is never fetched.
+func (f *fork) setLocal(addresses ...common.Address) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, address := range addresses {
+		f.loaded[address] = &loadedAccount{local: true}
+	}
+}
+
+// rewind forgets what was loaded after the block.
+func (f *fork) rewind(number uint64) {
+	for address, loaded := range f.loaded {
+		if loaded.number > number {
+			delete(f.loaded, address)
+			continue
+		}
+		for key, slotNumber := range loaded.slots {
+			if slotNumber > number {
+				delete(loaded.slots, key)
+			}
+		}
+	}
+}
+
+// accounts returns the remote state of the accounts, from the cache if possible.
+func (f *fork) accounts(ctx context.Context, addresses []common.Address) (map[common.Address]*forkAccount, error) {
+	accounts := make(map[common.Address]*forkAccount)
+	batch := make([]rpc.BatchElem, 0)
+	block := toBlockNumArg(f.header.Number)
+	for _, address := range addresses {
+		if account := f.cached(address); account != nil {
+			accounts[address] = account
+			continue
+		}
+		account := &forkAccount{Storage: make(map[common.Hash]common.Hash)}
+		accounts[address] = account
+		batch = append(batch,
+			rpc.BatchElem{Method: "eth_getBalance", Args: []interface{}{address, block}, Result: &account.Balance},
+			rpc.BatchElem{Method: "eth_getTransactionCount", Args: []interface{}{address, block}, Result: &account.Nonce},
+			rpc.BatchElem{Method: "eth_getCode", Args: []interface{}{address, block}, Result: &account.Code},
+		)
+	}
+	if err := f.batchCall(ctx, batch); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(batch); i += 3 {
+		address := batch[i].Args[0].(common.Address)
+		if err := f.store(address, accounts[address]); err != nil {
+			return nil, err
+		}
+	}
+	return accounts, nil
+}
+
+// storage returns the remote values of the storage slots of the account, from the
+// cache if possible.
+func (f *fork) storage(ctx context.Context, address common.Address, keys []common.Hash) (map[common.Hash]common.Hash, error) {
+	accounts, err := f.accounts(ctx, []common.Address{address})
+	if err != nil {
+		return nil, err
+	}
+	account := accounts[address]
+
+	storage := make(map[common.Hash]common.Hash)
+	batch := make([]rpc.BatchElem, 0)
+	values := make([]hexutil.Bytes, len(keys))
+	block := toBlockNumArg(f.header.Number)
+	for i, key := range keys {
+		if value, ok := account.Storage[key]; ok {
+			storage[key] = value
+			continue
+		}
+		batch = append(batch, rpc.BatchElem{Method: "eth_getStorageAt", Args: []interface{}{address, key, block}, Result: &values[i]})
+	}
+	if len(batch) == 0 {
+		return storage, nil
+	}
+	if err := f.batchCall(ctx, batch); err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		if _, ok := storage[key]; !ok {
+			storage[key] = common.BytesToHash(values[i])
+			account.Storage[key] = storage[key]
+		}
+	}
+	return storage, f.store(address, account)
+}
+
+func (f *fork) batchCall(ctx context.Context, batch []rpc.BatchElem) error {
+	for start := 0; start < len(batch); start += forkBatchSize {
+		end := start + forkBatchSize
+		if end > len(batch) {
+			end = len(batch)
+		}
+		if err := f.remote.BatchCallContext(ctx, batch[start:end]); err != nil {
+			return errors.Wrap(err, "fork")
+		}
+	}
+	for _, elem := range batch {
+		if elem.Error != nil {
+			return errors.Wrapf(elem.Error, "fork: %s", elem.Method)
+		}
+	}
+	return nil
+}
+
+// cached returns the account from memory or from the cache directory, nil if unknown.
+func (f *fork) cached(address common.Address) *forkAccount {
+	if account, ok := f.cache[address]; ok {
+		return account
+	}
+	if f.cacheDir == "" {
+		return nil
+	}
+	bytes, err := os.ReadFile(f.cachePath(address))
+	if err != nil {
+		return nil
+	}
+	account := new(forkAccount)
+	if err := json.Unmarshal(bytes, account); err != nil || account.Balance == nil {
+		return nil // fetched again and overwritten
+	}
+	if account.Storage == nil {
+		account.Storage = make(map[common.Hash]common.Hash)
+	}
+	f.cache[address] = account
+	return account
+}
+
+func (f *fork) store(address common.Address, account *forkAccount) error {
+	f.cache[address] = account
+	if f.cacheDir == "" {
+		return nil
+	}
+	bytes, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	tmp := f.cachePath(address) + ".tmp"
+	if err := os.WriteFile(tmp, bytes, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.cachePath(address))
+}
+
+func (f *fork) cachePath(address common.Address) string {
+	return filepath.Join(f.cacheDir, address.Hex()+".json")
+}
+
+// forkOverride is the remote state of an account the local state does not hold yet, as
+// a state override of eth_call and debug_traceCall. Only the storage slots are set for
+// accounts loaded before.
+type forkOverride struct {
+	Balance   *hexutil.Big                `json:"balance,omitempty"`
+	Nonce     *hexutil.Uint64             `json:"nonce,omitempty"`
+	Code      hexutil.Bytes               `json:"code,omitempty"`
+	StateDiff map[common.Hash]common.Hash `json:"stateDiff,omitempty"`
+}
+
+// remote reports whether the account itself comes from the fork, not only its storage.
+func (override *forkOverride) remote() bool {
+	return override != nil && override.Balance != nil
+}
+
+// forkLoad loads the remote state the call touches on top of the latest block, in a
+// single block.
+func (ec *Backend) forkLoad(ctx context.Context, call ethereum.CallMsg) error {
+	if ec.fork == nil {
+		return nil
+	}
+	ec.fork.lock.Lock()
+	defer ec.fork.lock.Unlock()
+
+	overrides, err := ec.forkOverrides(ctx, call)
+	if err != nil {
+		return err
+	}
+	addresses := make([]common.Address, 0, len(overrides))
+	slots := make(map[common.Address][]common.Hash)
+	for address, override := range overrides {
+		if _, ok := ec.fork.loaded[address]; !ok {
+			addresses = append(addresses, address)
+		}
+		for key := range override.StateDiff {
+			slots[address] = append(slots[address], key)
+		}
+	}
+	return ec.forkInject(ctx, addresses, slots)
+}
+
+// forkCallState returns the remote state the call on top of the latest block touches, as
+// state overrides, without writing it to the chain.
+func (ec *Backend) forkCallState(ctx context.Context, call ethereum.CallMsg) (map[common.Address]*forkOverride, error) {
+	if ec.fork == nil {
+		return nil, nil
+	}
+	ec.fork.lock.Lock()
+	defer ec.fork.lock.Unlock()
+	return ec.forkOverrides(ctx, call)
+}
+
+// forkAccountState returns the remote state of the account and of the storage slots the
+// latest state does not hold, nil if it holds all of it. Nothing is written to the chain.
+func (ec *Backend) forkAccountState(ctx context.Context, address common.Address, keys ...common.Hash) (*forkOverride, error) {
+	if ec.fork == nil {
+		return nil, nil
+	}
+	ec.fork.lock.Lock()
+	defer ec.fork.lock.Unlock()
+
+	chain := ec.eth.BlockChain()
+	statedb, err := chain.StateAt(chain.CurrentBlock().Root)
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[common.Address]*forkOverride)
+	if _, err := ec.forkState(ctx, statedb, overrides, map[common.Address][]common.Hash{address: keys}); err != nil {
+		return nil, err
+	}
+	return overrides[address], nil
+}
+
+// forkOverrides traces the call until it touches nothing new and returns the remote
+// state it touches as state overrides. Expects the lock of the fork to be held.
+func (ec *Backend) forkOverrides(ctx context.Context, call ethereum.CallMsg) (map[common.Address]*forkOverride, error) {
+	chain := ec.eth.BlockChain()
+	statedb, err := chain.StateAt(chain.CurrentBlock().Root)
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[common.Address]*forkOverride)
+
+	// the sender needs its balance before the call can be traced at all
+	touched := map[common.Address][]common.Hash{call.From: nil}
+	if call.To != nil {
+		touched[*call.To] = nil
+	}
+	if _, err := ec.forkState(ctx, statedb, overrides, touched); err != nil {
+		return nil, err
+	}
+
+	call.GasPrice, call.GasFeeCap, call.GasTipCap = nil, nil, nil
+	for i := 0; i < forkLoadRounds; i++ {
+		var prestate map[common.Address]struct {
+			Storage map[common.Hash]common.Hash `json:"storage"`
+		}
+		config := map[string]interface{}{"tracer": "prestateTracer", "stateOverrides": overrides}
+		if err := ec.rpc.CallContext(ctx, &prestate, "debug_traceCall", toCallArg(call), "latest", config); err != nil {
+			return nil, errors.Wrap(err, "fork")
+		}
+
+		touched := make(map[common.Address][]common.Hash, len(prestate))
+		for address, account := range prestate {
+			touched[address] = make([]common.Hash, 0, len(account.Storage))
+			for key := range account.Storage {
+				touched[address] = append(touched[address], key)
+			}
+		}
+		added, err := ec.forkState(ctx, statedb, overrides, touched)
+		if err != nil {
+			return nil, err
+		}
+		if !added {
+			return overrides, nil
+		}
+	}
+	return nil, fmt.Errorf("fork: the state touched by the call did not settle after %d lookups", forkLoadRounds)
+}
+
+// forkState adds the remote state of the touched accounts and storage slots that neither
+// statedb nor the overrides hold to the overrides, and reports whether it added any.
+// Accounts that exist in statedb without being loaded were created by local transactions.
+// Expects the lock of the fork to be held.
+func (ec *Backend) forkState(ctx context.Context, statedb *state.StateDB, overrides map[common.Address]*forkOverride, touched map[common.Address][]common.Hash) (bool, error) {
+	added := false
+	fetch := make([]common.Address, 0)
+	for address := range touched {
+		if _, ok := overrides[address]; ok {
+			continue
+		}
+		if _, ok := ec.fork.loaded[address]; ok || statedb.Exist(address) {
+			continue
+		}
+		fetch = append(fetch, address)
+		overrides[address] = &forkOverride{StateDiff: make(map[common.Hash]common.Hash)}
+		added = true
+	}
+	accounts, err := ec.fork.accounts(ctx, fetch)
+	if err != nil {
+		return false, err
+	}
+	for address, account := range accounts {
+		if account.empty() {
+			continue
+		}
+		nonce := account.Nonce
+		overrides[address].Balance = account.Balance
+		overrides[address].Nonce = &nonce
+		overrides[address].Code = account.Code
+	}
+
+	for address, keys := range touched {
+		override, loaded := overrides[address], ec.fork.loaded[address]
+		if override == nil && (loaded == nil || loaded.local) {
+			continue // local account
+		}
+		missing := make([]common.Hash, 0, len(keys))
+		for _, key := range keys {
+			if override != nil {
+				if _, ok := override.StateDiff[key]; ok {
+					continue
+				}
+			}
+			if loaded != nil {
+				if _, ok := loaded.slots[key]; ok {
+					continue
+				}
+			}
+			missing = append(missing, key)
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		values, err := ec.fork.storage(ctx, address, missing)
+		if err != nil {
+			return false, err
+		}
+		if override == nil {
+			override = &forkOverride{StateDiff: make(map[common.Hash]common.Hash)}
+			overrides[address] = override
+		}
+		for key, value := range values {
+			override.StateDiff[key] = value
+		}
+		added = true
+	}
+	return added, nil
+}
+
+// forkLoadTx loads the remote state the transaction touches.
+func (ec *Backend) forkLoadTx(ctx context.Context, tx *types.Transaction) error {
+	if ec.fork == nil {
+		return nil
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return err
+	}
+	return ec.forkLoad(ctx, ethereum.CallMsg{
+		From:       from,
+		To:         tx.To(),
+		Gas:        tx.Gas(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	})
+}
+
+// forkLoadAccount loads the remote state of the account and of the storage slots, for
+// the reads of the latest state.
+func (ec *Backend) forkLoadAccount(ctx context.Context, blockNumber *big.Int, address common.Address, keys ...common.Hash) error {
+	if ec.fork == nil || blockNumber != nil {
+		return nil
+	}
+	ec.fork.lock.Lock()
+	defer ec.fork.lock.Unlock()
+
+	loaded := ec.fork.loaded[address]
+	if loaded != nil && loaded.local {
+		return nil
+	}
+	missing := make([]common.Hash, 0, len(keys))
+	for _, key := range keys {
+		if loaded != nil {
+			if _, ok := loaded.slots[key]; ok {
+				continue
+			}
+		}
+		missing = append(missing, key)
+	}
+	if loaded != nil && len(missing) == 0 {
+		return nil
+	}
+	return ec.forkInject(ctx, ec.fork.unloaded(address), map[common.Address][]common.Hash{address: missing})
+}
+
+// unloaded filters out the accounts the local state holds. Expects the lock to be held.
+func (f *fork) unloaded(addresses ...common.Address) []common.Address {
+	unloaded := make([]common.Address, 0, len(addresses))
+	for _, address := range addresses {
+		if _, ok := f.loaded[address]; !ok {
+			unloaded = append(unloaded, address)
+		}
+	}
+	return unloaded
+}
+
+// forkInject fetches the accounts and storage slots and writes them to the state of the
+// latest block. Accounts that already exist locally were created by local transactions
+// and are marked local instead. Expects the lock of the fork to be held.
+func (ec *Backend) forkInject(ctx context.Context, addresses []common.Address, slots map[common.Address][]common.Hash) error {
+	if len(addresses) == 0 && len(slots) == 0 {
+		return nil
+	}
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	chain := ec.eth.BlockChain()
+	head := chain.CurrentBlock()
+	statedb, err := chain.StateAt(head.Root)
+	if err != nil {
+		return err
+	}
+
+	remote := make([]common.Address, 0, len(addresses))
+	local := make([]common.Address, 0)
+	for _, address := range addresses {
+		if statedb.Exist(address) {
+			local = append(local, address)
+		} else {
+			remote = append(remote, address)
+		}
+	}
+	accounts, err := ec.fork.accounts(ctx, remote)
+	if err != nil {
+		return err
+	}
+
+	dirty := false
+	for address, account := range accounts {
+		if account.empty() {
+			continue
+		}
+		statedb.SetBalance(address, uint256.MustFromBig(account.Balance.ToInt()))
+		statedb.SetNonce(address, uint64(account.Nonce))
+		statedb.SetCode(address, account.Code)
+		dirty = true
+	}
+
+	storage := make(map[common.Address]map[common.Hash]common.Hash)
+	for address, keys := range slots {
+		if loaded := ec.fork.loaded[address]; (loaded == nil || loaded.local) && accounts[address] == nil {
+			continue // local account
+		}
+		values, err := ec.fork.storage(ctx, address, keys)
+		if err != nil {
+			return err
+		}
+		for key, value := range values {
+			if value != (common.Hash{}) {
+				statedb.SetState(address, key, value)
+				dirty = true
+			}
+		}
+		storage[address] = values
+	}
+
+	number := head.Number.Uint64()
+	if dirty {
+		if err := ec.writeState(head, statedb); err != nil {
+			return errors.Wrap(err, "fork")
+		}
+		number++
+	}
+
+	for _, address := range local {
+		ec.fork.loaded[address] = &loadedAccount{number: number, local: true}
+	}
+	for address := range accounts {
+		ec.fork.loaded[address] = &loadedAccount{number: number, slots: make(map[common.Hash]uint64)}
+	}
+	for address, values := range storage {
+		for key := range values {
+			ec.fork.loaded[address].slots[key] = number
+		}
+	}
+	return nil
+}
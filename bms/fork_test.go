@@ -0,0 +1,153 @@
+package bms_test
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFork(t *testing.T) {
+	ctx := context.Background()
+	remote := bms.NewBacked(t, bms.WithHTTP("127.0.0.1", 0))
+	txpool := bmsutils.NewTxPool(remote)
+
+	counter, err := abi.JSON(strings.NewReader(counterABI))
+	require.NoError(t, err)
+	counterAddr, _, counterContract, err := bind.DeployContract(remote.Owner, counter, deployCode(counterCode()), remote)
+	require.NoError(t, err)
+	inner, err := abi.JSON(strings.NewReader(innerABI))
+	require.NoError(t, err)
+	outer, err := abi.JSON(strings.NewReader(outerABI))
+	require.NoError(t, err)
+	innerAddr, _, _, err := bind.DeployContract(remote.Owner, inner, deployCode(innerCode(&inner)), remote)
+	require.NoError(t, err)
+	outerAddr, _, _, err := bind.DeployContract(remote.Owner, outer, deployCode(outerCode(&inner)), remote)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, txpool.Exec(counterContract.Transact(remote.Owner, "increment")))
+	}
+	receiver := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	remote.Owner.Value = bmsutils.ToWei(3)
+	require.NoError(t, txpool.Exec(bmsutils.SendDynamicTx(remote, remote.Owner, &receiver, []byte{})))
+	remote.Owner.Value = common.Big0
+	require.NoError(t, txpool.AllReceiptStatusSuccessful(ctx))
+
+	number, err := remote.BlockNumber(ctx)
+	require.NoError(t, err)
+	// moves the remote chain past the forked block
+	require.NoError(t, txpool.Exec(counterContract.Transact(remote.Owner, "increment")))
+	require.NoError(t, txpool.AllReceiptStatusSuccessful(ctx))
+
+	cache := t.TempDir()
+	forked := bms.NewBacked(t, bms.WithFork(remote.HTTPEndpoint(), new(big.Int).SetUint64(number)), bms.WithForkCache(cache))
+
+	balance, err := forked.BalanceAt(ctx, receiver, nil)
+	require.NoError(t, err)
+	require.Equal(t, bmsutils.ToWei(3), balance)
+
+	slot, err := forked.StorageAt(ctx, counterAddr, common.Hash{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), new(big.Int).SetBytes(slot).Int64())
+	// reads don't seal blocks, and the local chain numbers its blocks from 0
+	head, err := forked.BlockNumber(ctx)
+	require.NoError(t, err)
+	require.Zero(t, head)
+
+	// the storage of the counter is loaded by the transaction itself
+	forkedCounter := bind.NewBoundContract(counterAddr, counter, forked, forked, forked)
+	forkedPool := bmsutils.NewTxPool(forked)
+	require.NoError(t, forkedPool.Exec(forkedCounter.Transact(forked.Owner, "increment")))
+	require.NoError(t, forkedPool.AllReceiptStatusSuccessful(ctx))
+	slot, err = forked.StorageAt(ctx, counterAddr, common.Hash{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), new(big.Int).SetBytes(slot).Int64())
+
+	// outer calls inner, whose code is only known once outer runs
+	bmsutils.EnrollErrors(&inner)
+	forkedOuter := bind.NewBoundContract(outerAddr, outer, forked, forked, forked)
+	_, err = forkedOuter.Transact(forked.Owner, "call", innerAddr, big.NewInt(7))
+	require.EqualError(t, err, "Boom[7]")
+
+	// a new fork reads the same block from the cache
+	entries, err := os.ReadDir(cache)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	again := bms.NewBacked(t, bms.WithFork(remote.HTTPEndpoint(), new(big.Int).SetUint64(number)), bms.WithForkCache(cache))
+	slot, err = again.StorageAt(ctx, counterAddr, common.Hash{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), new(big.Int).SetBytes(slot).Int64())
+}
+
+func TestForkJSONRPC(t *testing.T) {
+	ctx := context.Background()
+	remote := bms.NewBacked(t, bms.WithHTTP("127.0.0.1", 0))
+
+	// every reader returns its first storage slot, 5
+	reader := program(push([]byte{0}), op(vm.SLOAD), push([]byte{0}), op(vm.MSTORE), push([]byte{32}), push([]byte{0}), op(vm.RETURN))
+	readers := make([]common.Address, 3)
+	for i := range readers {
+		readers[i] = common.BigToAddress(big.NewInt(int64(0xaa + i)))
+		require.NoError(t, remote.SetCode(readers[i], reader))
+		require.NoError(t, remote.SetStorageAt(readers[i], common.Hash{}, common.BigToHash(big.NewInt(5))))
+	}
+	account := bms.GetTEoa(t).From
+	require.NoError(t, remote.SetBalance(account, bmsutils.ToWei(3)))
+	require.NoError(t, remote.SetNonce(account, 7))
+	remoteGas, err := remote.EstimateGas(ctx, ethereum.CallMsg{To: &readers[1]})
+	require.NoError(t, err)
+
+	forked := bms.NewBacked(t, bms.WithFork(remote.HTTPEndpoint(), nil), bms.WithForkCache(""), bms.WithHTTP("127.0.0.1", 0))
+	client, err := ethclient.DialContext(ctx, forked.HTTPEndpoint())
+	require.NoError(t, err)
+	defer client.Close()
+
+	// every account is read over JSON-RPC before anything else loads it
+	output, err := client.CallContract(ctx, ethereum.CallMsg{To: &readers[0]}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), new(big.Int).SetBytes(output).Int64())
+
+	gas, err := client.EstimateGas(ctx, ethereum.CallMsg{To: &readers[1]})
+	require.NoError(t, err)
+	require.Equal(t, remoteGas, gas)
+
+	code, err := client.CodeAt(ctx, readers[2], nil)
+	require.NoError(t, err)
+	require.Equal(t, reader, code)
+	slot, err := client.StorageAt(ctx, readers[2], common.Hash{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), new(big.Int).SetBytes(slot).Int64())
+
+	balance, err := client.BalanceAt(ctx, account, nil)
+	require.NoError(t, err)
+	require.Equal(t, bmsutils.ToWei(3), balance)
+	nonce, err := client.PendingNonceAt(ctx, account)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), nonce)
+
+	// the state overrides of the client apply on top of the fork
+	var overridden hexutil.Bytes
+	overrides := map[common.Address]interface{}{
+		readers[0]: map[string]interface{}{"stateDiff": map[common.Hash]common.Hash{{}: common.BigToHash(big.NewInt(9))}},
+	}
+	require.NoError(t, client.Client().CallContext(ctx, &overridden, "eth_call", map[string]interface{}{"to": readers[0]}, "latest", overrides))
+	require.Equal(t, int64(9), new(big.Int).SetBytes(overridden).Int64())
+
+	// reads don't seal blocks
+	number, err := client.BlockNumber(ctx)
+	require.NoError(t, err)
+	require.Zero(t, number)
+}
@@ -0,0 +1,300 @@
+package bms
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// GasReportEnv names the file GasReportMain writes the report to. A path ending with
+// .json is written as JSON, an existing directory receives one JSON report per test
+// binary, anything else is written as a table. Unset, the table is printed to stdout.
+const GasReportEnv string = "BMS_GAS_REPORT"
+
+// gasReporter is the reporter of the backends created by NewBacked, set by GasReportMain.
+var gasReporter *GasReporter
+
+// GasReportMain runs the tests with a gas reporter and writes the report at the end.
+// Use it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(bms.GasReportMain(m))
+//	}
+func GasReportMain(m *testing.M) int {
+	gasReporter = NewGasReporter()
+	code := m.Run()
+	if err := gasReporter.Write(os.Getenv(GasReportEnv)); err != nil {
+		fmt.Fprintln(os.Stderr, "gas report:", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// GasStats is the gas used by the calls to a contract method, or by its deployments.
+type GasStats struct {
+	Contract string `json:"contract"`
+	Method   string `json:"method,omitempty"`
+	Calls    uint64 `json:"calls"`
+	Min      uint64 `json:"min"`
+	Max      uint64 `json:"max"`
+	Total    uint64 `json:"total"`
+}
+
+// Avg returns the average gas used per call.
+func (stats *GasStats) Avg() uint64 {
+	if stats.Calls == 0 {
+		return 0
+	}
+	return stats.Total / stats.Calls
+}
+
+func (stats *GasStats) add(other *GasStats) {
+	if stats.Calls == 0 || other.Min < stats.Min {
+		stats.Min = other.Min
+	}
+	if other.Max > stats.Max {
+		stats.Max = other.Max
+	}
+	stats.Calls += other.Calls
+	stats.Total += other.Total
+}
+
+// GasReport is the gas used per contract method and per contract deployment.
+type GasReport struct {
+	Methods     []*GasStats `json:"methods"`
+	Deployments []*GasStats `json:"deployments"`
+}
+
+// ReadGasReport reads a JSON gas report, or merges all the reports of a directory.
+func ReadGasReport(path string) (*GasReport, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, err
+		}
+	}
+
+	reporter := NewGasReporter()
+	for _, file := range files {
+		bytes, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		report := new(GasReport)
+		if err := json.Unmarshal(bytes, report); err != nil {
+			return nil, errors.Wrap(err, file)
+		}
+		reporter.Merge(report)
+	}
+	return reporter.Report(), nil
+}
+
+// WriteTable writes the report as a table, nothing if no gas was recorded.
+func (report *GasReport) WriteTable(w io.Writer) error {
+	if len(report.Methods) == 0 && len(report.Deployments) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Contract\tMethod\tMin\tMax\tAvg\t# calls\t")
+	for _, stats := range report.Methods {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t\n", stats.Contract, stats.Method, stats.Min, stats.Max, stats.Avg(), stats.Calls)
+	}
+	if len(report.Deployments) != 0 {
+		fmt.Fprintln(tw, "\t\t\t\t\t\t")
+		fmt.Fprintln(tw, "Deployments\t\tMin\tMax\tAvg\t# deploys\t")
+		for _, stats := range report.Deployments {
+			fmt.Fprintf(tw, "%s\t\t%d\t%d\t%d\t%d\t\n", stats.Contract, stats.Min, stats.Max, stats.Avg(), stats.Calls)
+		}
+	}
+	return tw.Flush()
+}
+
+// GasReporter aggregates the gas used by the transactions mined on backends.
+type GasReporter struct {
+	lock        sync.Mutex
+	methods     map[[2]string]*GasStats
+	deployments map[string]*GasStats
+}
+
+func NewGasReporter() *GasReporter {
+	return &GasReporter{
+		methods:     make(map[[2]string]*GasStats),
+		deployments: make(map[string]*GasStats),
+	}
+}
+
+// RecordCall records the gas used by a call to the method of the contract.
+func (reporter *GasReporter) RecordCall(contract, method string, gasUsed uint64) {
+	reporter.lock.Lock()
+	defer reporter.lock.Unlock()
+	reporter.recordCall(&GasStats{Contract: contract, Method: method, Calls: 1, Min: gasUsed, Max: gasUsed, Total: gasUsed})
+}
+
+// RecordDeployment records the gas used by a deployment of the contract.
+func (reporter *GasReporter) RecordDeployment(contract string, gasUsed uint64) {
+	reporter.lock.Lock()
+	defer reporter.lock.Unlock()
+	reporter.recordDeployment(&GasStats{Contract: contract, Calls: 1, Min: gasUsed, Max: gasUsed, Total: gasUsed})
+}
+
+// Merge adds the stats of the report to the reporter.
+func (reporter *GasReporter) Merge(report *GasReport) {
+	reporter.lock.Lock()
+	defer reporter.lock.Unlock()
+	for _, stats := range report.Methods {
+		reporter.recordCall(stats)
+	}
+	for _, stats := range report.Deployments {
+		reporter.recordDeployment(stats)
+	}
+}
+
+func (reporter *GasReporter) recordCall(stats *GasStats) {
+	key := [2]string{stats.Contract, stats.Method}
+	if _, ok := reporter.methods[key]; !ok {
+		reporter.methods[key] = &GasStats{Contract: stats.Contract, Method: stats.Method}
+	}
+	reporter.methods[key].add(stats)
+}
+
+func (reporter *GasReporter) recordDeployment(stats *GasStats) {
+	if _, ok := reporter.deployments[stats.Contract]; !ok {
+		reporter.deployments[stats.Contract] = &GasStats{Contract: stats.Contract}
+	}
+	reporter.deployments[stats.Contract].add(stats)
+}
+
+// Report returns the aggregated stats sorted by contract and method.
+func (reporter *GasReporter) Report() *GasReport {
+	reporter.lock.Lock()
+	defer reporter.lock.Unlock()
+
+	report := &GasReport{
+		Methods:     make([]*GasStats, 0, len(reporter.methods)),
+		Deployments: make([]*GasStats, 0, len(reporter.deployments)),
+	}
+	for _, stats := range reporter.methods {
+		copied := *stats
+		report.Methods = append(report.Methods, &copied)
+	}
+	for _, stats := range reporter.deployments {
+		copied := *stats
+		report.Deployments = append(report.Deployments, &copied)
+	}
+	sort.Slice(report.Methods, func(i, j int) bool {
+		if report.Methods[i].Contract != report.Methods[j].Contract {
+			return report.Methods[i].Contract < report.Methods[j].Contract
+		}
+		return report.Methods[i].Method < report.Methods[j].Method
+	})
+	sort.Slice(report.Deployments, func(i, j int) bool {
+		return report.Deployments[i].Contract < report.Deployments[j].Contract
+	})
+	return report
+}
+
+// Write writes the report to path as described by GasReportEnv.
+func (reporter *GasReporter) Write(path string) error {
+	report := reporter.Report()
+	if path == "" {
+		return report.WriteTable(os.Stdout)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		file, err := os.CreateTemp(path, "gas-report-*.json")
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return json.NewEncoder(file).Encode(report)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if filepath.Ext(path) == ".json" {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return report.WriteTable(file)
+}
+
+// gasRecord is the gas used by a mined transaction, named when the backend is closed
+// so that the contracts enrolled after their deployment are known.
+type gasRecord struct {
+	to       *common.Address
+	contract common.Address
+	sig      bmsutils.Sig
+	gasUsed  uint64
+}
+
+// recordGas keeps the gas used by the successful transactions of the block.
+func (ec *Backend) recordGas(block *types.Block, receipts types.Receipts) {
+	if ec.gasReporter == nil {
+		return
+	}
+
+	ec.gasLock.Lock()
+	defer ec.gasLock.Unlock()
+	for i, tx := range block.Transactions() {
+		receipt := receipts[i]
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+		record := gasRecord{to: tx.To(), contract: receipt.ContractAddress, gasUsed: receipt.GasUsed}
+		if tx.To() != nil {
+			record.contract = *tx.To()
+			if len(tx.Data()) < 4 {
+				continue // plain transfers are not method calls
+			}
+			record.sig = bmsutils.Sig(tx.Data()[:4])
+		}
+		ec.gasRecords = append(ec.gasRecords, record)
+	}
+}
+
+// reportGas names the recorded transactions and hands them to the gas reporter.
+func (ec *Backend) reportGas() {
+	if ec.gasReporter == nil {
+		return
+	}
+
+	ec.gasLock.Lock()
+	defer ec.gasLock.Unlock()
+	for _, record := range ec.gasRecords {
+		contract := record.contract.Hex()
+		if enrolled, ok := bmsutils.GetEnrolledContract(record.contract); ok && enrolled.Name != "" {
+			contract = enrolled.Name
+		}
+		if record.to == nil {
+			ec.gasReporter.RecordDeployment(contract, record.gasUsed)
+			continue
+		}
+		method := fmt.Sprintf("0x%x", record.sig[:])
+		if enrolled, ok := bmsutils.GetEnrolledMethod(record.contract, record.sig); ok {
+			method = enrolled.Name
+		}
+		ec.gasReporter.RecordCall(contract, method, record.gasUsed)
+	}
+	ec.gasRecords = nil
+}
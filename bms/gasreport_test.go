@@ -0,0 +1,70 @@
+package bms_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/require"
+)
+
+const counterABI = `[{"type":"function","name":"increment","inputs":[],"outputs":[],"stateMutability":"nonpayable"}]`
+
+// counterCode increments the first storage slot on every call.
+func counterCode() []byte {
+	return program(
+		push([]byte{0}), op(vm.SLOAD), push([]byte{1}), op(vm.ADD), push([]byte{0}), op(vm.SSTORE), op(vm.STOP),
+	)
+}
+
+func TestGasReport(t *testing.T) {
+	reporter := bms.NewGasReporter()
+
+	t.Run("record", func(t *testing.T) {
+		backend := bms.NewBacked(t, bms.WithGasReporter(reporter))
+		ctx := context.Background()
+
+		counter, err := abi.JSON(strings.NewReader(counterABI))
+		require.NoError(t, err)
+		address, _, contract, err := bind.DeployContract(backend.Owner, counter, deployCode(counterCode()), backend)
+		require.NoError(t, err)
+		bmsutils.EnrollContract(address, "Counter", &counter)
+
+		txpool := bmsutils.NewTxPool(backend)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, txpool.Exec(contract.Transact(backend.Owner, "increment")))
+		}
+		require.NoError(t, txpool.AllReceiptStatusSuccessful(ctx))
+	})
+
+	report := reporter.Report()
+	require.Len(t, report.Methods, 1)
+	require.Equal(t, "Counter", report.Methods[0].Contract)
+	require.Equal(t, "increment", report.Methods[0].Method)
+	require.Equal(t, uint64(3), report.Methods[0].Calls)
+	require.Less(t, report.Methods[0].Min, report.Methods[0].Max)
+	require.Len(t, report.Deployments, 1)
+	require.Equal(t, "Counter", report.Deployments[0].Contract)
+
+	var table bytes.Buffer
+	require.NoError(t, report.WriteTable(&table))
+	t.Log("\n" + table.String())
+
+	// an empty report prints nothing
+	table.Reset()
+	require.NoError(t, bms.NewGasReporter().Report().WriteTable(&table))
+	require.Empty(t, table.String())
+
+	path := filepath.Join(t.TempDir(), "gas.json")
+	require.NoError(t, reporter.Write(path))
+	read, err := bms.ReadGasReport(path)
+	require.NoError(t, err)
+	require.Equal(t, report, read)
+}
@@ -0,0 +1,223 @@
+package bms
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GasSnapshotFileEnv overrides the snapshot file, .gas-snapshot in the working directory
+	// of the test by default.
+	GasSnapshotFileEnv string = "BMS_GAS_SNAPSHOT"
+	// GasSnapshotModeEnv selects the snapshot mode: check (default), warn or update.
+	GasSnapshotModeEnv string = "BMS_GAS_SNAPSHOT_MODE"
+	// GasSnapshotToleranceEnv is the gas increase allowed, in percent of the stored value.
+	GasSnapshotToleranceEnv string = "BMS_GAS_SNAPSHOT_TOLERANCE"
+
+	DefaultGasSnapshotFile string = ".gas-snapshot"
+)
+
+// GasSnapshotMode decides what happens when a measurement grows beyond the tolerance.
+type GasSnapshotMode string
+
+const (
+	// GasSnapshotCheck fails the test.
+	GasSnapshotCheck GasSnapshotMode = "check"
+	// GasSnapshotWarn only logs the difference.
+	GasSnapshotWarn GasSnapshotMode = "warn"
+	// GasSnapshotUpdate overwrites the stored values with the new measurements.
+	GasSnapshotUpdate GasSnapshotMode = "update"
+)
+
+type GasSnapshotConfig struct {
+	File      string
+	Mode      GasSnapshotMode
+	Tolerance float64
+}
+
+// GasSnapshotConfigFromEnv reads the configuration of GasSnapshot from the environment.
+func GasSnapshotConfigFromEnv() (GasSnapshotConfig, error) {
+	conf := GasSnapshotConfig{
+		File: os.Getenv(GasSnapshotFileEnv),
+		Mode: GasSnapshotMode(os.Getenv(GasSnapshotModeEnv)),
+	}
+	if conf.File == "" {
+		conf.File = DefaultGasSnapshotFile
+	}
+	switch conf.Mode {
+	case "":
+		conf.Mode = GasSnapshotCheck
+	case GasSnapshotCheck, GasSnapshotWarn, GasSnapshotUpdate:
+	default:
+		return conf, fmt.Errorf("%s: unknown mode %q", GasSnapshotModeEnv, conf.Mode)
+	}
+	if tolerance := os.Getenv(GasSnapshotToleranceEnv); tolerance != "" {
+		var err error
+		if conf.Tolerance, err = strconv.ParseFloat(tolerance, 64); err != nil {
+			return conf, errors.Wrap(err, GasSnapshotToleranceEnv)
+		}
+	}
+	return conf, nil
+}
+
+var (
+	gasSnapshots     *GasSnapshots
+	gasSnapshotsErr  error
+	gasSnapshotsOnce sync.Once
+)
+
+// GasSnapshot compares the gas used by the receipt with the value stored under the name of
+// the test and name in the snapshot file, and stores it when there is none. See
+// GasSnapshotConfigFromEnv.
+func GasSnapshot(t testing.TB, name string, receipt *types.Receipt) {
+	t.Helper()
+	gasSnapshotsOnce.Do(func() {
+		var conf GasSnapshotConfig
+		if conf, gasSnapshotsErr = GasSnapshotConfigFromEnv(); gasSnapshotsErr == nil {
+			gasSnapshots, gasSnapshotsErr = NewGasSnapshots(conf)
+		}
+	})
+	if gasSnapshotsErr != nil {
+		t.Fatal("gas snapshot:", gasSnapshotsErr)
+	}
+	gasSnapshots.Record(t, name, receipt.GasUsed)
+}
+
+// GasSnapshots is a set of named gas measurements persisted to a snapshot file.
+type GasSnapshots struct {
+	conf   GasSnapshotConfig
+	lock   sync.Mutex
+	stored map[string]uint64
+	// recorded are the names recorded by the running tests.
+	recorded map[testing.TB]map[string]struct{}
+}
+
+var gasSnapshotLine = regexp.MustCompile(`^(.+) \(gas: (\d+)\)$`)
+
+// NewGasSnapshots loads the snapshot file of the config, if it exists.
+func NewGasSnapshots(conf GasSnapshotConfig) (*GasSnapshots, error) {
+	snapshots := &GasSnapshots{
+		conf:     conf,
+		stored:   make(map[string]uint64),
+		recorded: make(map[testing.TB]map[string]struct{}),
+	}
+
+	file, err := os.Open(conf.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshots, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		match := gasSnapshotLine.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("%s: invalid line %q", conf.File, line)
+		}
+		gas, err := strconv.ParseUint(match[2], 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, conf.File)
+		}
+		snapshots.stored[match[1]] = gas
+	}
+	return snapshots, scanner.Err()
+}
+
+// Record compares gas with the value stored under the name of the test and name, e.g.
+// TestTransfer/transfer like the contract:test of forge, or stores it. A test records a
+// name once.
+func (snapshots *GasSnapshots) Record(t testing.TB, name string, gas uint64) {
+	t.Helper()
+	snapshots.lock.Lock()
+	defer snapshots.lock.Unlock()
+
+	recorded, ok := snapshots.recorded[t]
+	if !ok {
+		recorded = make(map[string]struct{})
+		snapshots.recorded[t] = recorded
+		t.Cleanup(func() {
+			snapshots.lock.Lock()
+			defer snapshots.lock.Unlock()
+			delete(snapshots.recorded, t)
+		})
+	}
+	if _, ok := recorded[name]; ok {
+		t.Errorf("gas snapshot %q is recorded twice", name)
+		return
+	}
+	recorded[name] = struct{}{}
+
+	key := t.Name() + "/" + name
+	stored, ok := snapshots.stored[key]
+	if !ok || snapshots.conf.Mode == GasSnapshotUpdate {
+		if ok && stored != gas {
+			t.Logf("gas snapshot %q updated: %d -> %d (%s)", key, stored, gas, gasDiff(stored, gas))
+		}
+		snapshots.stored[key] = gas
+		if err := snapshots.write(); err != nil {
+			t.Errorf("gas snapshot %q: %v", key, err)
+		}
+		return
+	}
+
+	if gas == stored {
+		return
+	}
+	message := fmt.Sprintf("gas snapshot %q changed: %d -> %d (%s)", key, stored, gas, gasDiff(stored, gas))
+	limit := float64(stored) * (1 + snapshots.conf.Tolerance/100)
+	if float64(gas) > limit && snapshots.conf.Mode == GasSnapshotCheck {
+		t.Errorf("%s, beyond the tolerance of %v%%", message, snapshots.conf.Tolerance)
+	} else {
+		t.Log(message)
+	}
+}
+
+// write expects the lock to be held.
+func (snapshots *GasSnapshots) write() error {
+	names := make([]string, 0, len(snapshots.stored))
+	for name := range snapshots.stored {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&builder, "%s (gas: %d)\n", name, snapshots.stored[name])
+	}
+
+	tmp := snapshots.conf.File + ".tmp"
+	if dir := filepath.Dir(snapshots.conf.File); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(tmp, []byte(builder.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, snapshots.conf.File)
+}
+
+func gasDiff(stored, gas uint64) string {
+	diff := int64(gas) - int64(stored)
+	if stored == 0 {
+		return fmt.Sprintf("%+d", diff)
+	}
+	return fmt.Sprintf("%+d, %+.2f%%", diff, float64(diff)*100/float64(stored))
+}
@@ -0,0 +1,95 @@
+package bms_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder captures the failures and logs of GasSnapshots.Record.
+type recorder struct {
+	testing.TB
+	errors []string
+	logs   []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Log(args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprint(args...))
+}
+
+func (r *recorder) Logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func TestGasSnapshot(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".gas-snapshot")
+	run := func(mode bms.GasSnapshotMode, gas uint64) *recorder {
+		snapshots, err := bms.NewGasSnapshots(bms.GasSnapshotConfig{File: file, Mode: mode, Tolerance: 1})
+		require.NoError(t, err)
+		r := &recorder{TB: t}
+		snapshots.Record(r, "transfer", gas)
+		return r
+	}
+
+	r := run(bms.GasSnapshotCheck, 50000)
+	require.Empty(t, r.errors)
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "TestGasSnapshot/transfer (gas: 50000)\n", string(content))
+
+	r = run(bms.GasSnapshotCheck, 50400) // +0.8%, within the tolerance
+	require.Empty(t, r.errors)
+	require.Len(t, r.logs, 1)
+
+	r = run(bms.GasSnapshotCheck, 51000) // +2%
+	require.Len(t, r.errors, 1)
+	t.Log(r.errors[0])
+
+	r = run(bms.GasSnapshotWarn, 51000)
+	require.Empty(t, r.errors)
+	require.Len(t, r.logs, 1)
+
+	r = run(bms.GasSnapshotUpdate, 51000)
+	require.Empty(t, r.errors)
+	content, err = os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "TestGasSnapshot/transfer (gas: 51000)\n", string(content))
+}
+
+func TestGasSnapshotPerTest(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".gas-snapshot")
+
+	snapshots, err := bms.NewGasSnapshots(bms.GasSnapshotConfig{File: file})
+	require.NoError(t, err)
+
+	// tests recording the same name keep their own values instead of comparing them
+	for _, test := range []struct {
+		name string
+		gas  uint64
+	}{{"small", 50000}, {"large", 70000}} {
+		t.Run(test.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			snapshots.Record(r, "transfer", test.gas)
+			require.Empty(t, r.errors)
+			require.Empty(t, r.logs)
+		})
+	}
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "TestGasSnapshotPerTest/large/transfer (gas: 70000)\nTestGasSnapshotPerTest/small/transfer (gas: 50000)\n", string(content))
+
+	r := &recorder{TB: t}
+	snapshots.Record(r, "transfer", 50000)
+	snapshots.Record(r, "transfer", 50000)
+	require.Equal(t, []string{`gas snapshot "transfer" is recorded twice`}, r.errors)
+}
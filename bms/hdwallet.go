@@ -1,62 +1,203 @@
 package bms
 
 import (
+	"crypto/ecdsa"
+	"hash/fnv"
 	"math/big"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	hdwallet "github.com/miguelmota/go-ethereum-hdwallet"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
+const (
+	// DefaultMnemonic is the mnemonic of the wallet unless another one is configured, the
+	// one of hardhat and anvil, so the test accounts are the same on every machine.
+	DefaultMnemonic string = "test test test test test test test test test test test junk"
+
+	// MnemonicEnv holds the mnemonic of the wallet.
+	MnemonicEnv string = "BMS_MNEMONIC"
+	// MnemonicFileEnv names a file holding the mnemonic of the wallet.
+	MnemonicFileEnv string = "BMS_MNEMONIC_FILE"
+)
+
 var (
-	mnemonicFile string = filepath.Join(os.Getenv("HOME"), ".bms-mnemonic")
-	wallet       *hdwallet.Wallet
-	eoaTCount    uint32 = 0
-	eoaCount     uint32 = 0
+	walletLock sync.Mutex
+	wallet     *hdwallet.Wallet
+	eoaCount   uint32 = 0
 )
 
-func init() {
-	if _, err := os.Stat(mnemonicFile); os.IsNotExist(err) {
-		mnemonic, err := hdwallet.NewMnemonic(192)
-		if err != nil {
-			panic(err)
-		}
-		err = os.WriteFile(mnemonicFile, []byte(mnemonic), 0644)
-		if err != nil {
-			panic(err)
+// UseMnemonic sets the mnemonic of the wallet the test, backend and node accounts are
+// derived from, instead of the configured one.
+func UseMnemonic(mnemonic string) error {
+	loaded, err := hdwallet.NewFromMnemonic(strings.TrimSpace(mnemonic))
+	if err != nil {
+		return errors.Wrap(err, "mnemonic")
+	}
+	walletLock.Lock()
+	defer walletLock.Unlock()
+	wallet = loaded
+	return nil
+}
+
+// getWallet returns the wallet, loading on first use the mnemonic of MnemonicEnv, of the
+// file of MnemonicFileEnv, of the wallet of the project config, or DefaultMnemonic.
+func getWallet() (*hdwallet.Wallet, error) {
+	walletLock.Lock()
+	defer walletLock.Unlock()
+	if wallet != nil {
+		return wallet, nil
+	}
+
+	mnemonic, err := configuredMnemonic()
+	if err != nil {
+		return nil, err
+	}
+	if wallet, err = hdwallet.NewFromMnemonic(mnemonic); err != nil {
+		return nil, errors.Wrap(err, "mnemonic")
+	}
+	return wallet, nil
+}
+
+func configuredMnemonic() (string, error) {
+	mnemonic, file, err := mnemonicSource()
+	switch {
+	case err != nil:
+		return "", err
+	case mnemonic != "":
+		return strings.TrimSpace(mnemonic), nil
+	case file != "":
+		return readMnemonicFile(file)
+	}
+	return DefaultMnemonic, nil
+}
+
+// MnemonicFile returns the file the mnemonic of the wallet is read from, "" if it is not
+// read from a file.
+func MnemonicFile() (string, error) {
+	_, file, err := mnemonicSource()
+	return file, err
+}
+
+// mnemonicSource returns the mnemonic of MnemonicEnv or of the project config, or else
+// the file to read it from, both empty for DefaultMnemonic.
+func mnemonicSource() (mnemonic string, file string, err error) {
+	if mnemonic := os.Getenv(MnemonicEnv); mnemonic != "" {
+		return mnemonic, "", nil
+	}
+	if path := os.Getenv(MnemonicFileEnv); path != "" {
+		return "", expandHome(path), nil
+	}
+
+	path, err := findConfigFile()
+	if err != nil || path == "" {
+		return "", "", err
+	}
+	conf, err := ReadConfig(path)
+	if err != nil {
+		return "", "", err
+	}
+	if conf.Wallet.Mnemonic != "" {
+		return conf.Wallet.Mnemonic, "", nil
+	}
+	if file = conf.Wallet.MnemonicFile; file != "" {
+		if file = expandHome(file); !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
 		}
 	}
-	mnemonic, err := os.ReadFile(mnemonicFile)
+	return "", file, nil
+}
+
+func readMnemonicFile(path string) (string, error) {
+	mnemonic, err := os.ReadFile(expandHome(path))
 	if err != nil {
-		panic(err)
+		return "", errors.Wrap(err, "mnemonic file")
 	}
-	wallet, err = hdwallet.NewFromMnemonic(string(mnemonic))
+	return strings.TrimSpace(string(mnemonic)), nil
+}
+
+// expandHome replaces the leading ~ of the path with the home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// findConfigFile returns the project config of ConfigFileEnv, or the closest bms.json from
+// the working directory up to the go.mod of the module, "" if there is none.
+func findConfigFile() (string, error) {
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		return path, nil
+	}
+	dir, err := os.Getwd()
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, DefaultConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil || filepath.Dir(dir) == dir {
+			return "", nil
+		}
+		dir = filepath.Dir(dir)
 	}
 }
 
+// The accounts of the wallet, m/44'/60'/{account}'/0/{index}:
+//   - 0': the owner and the accounts of the backends and nodes, GetEoaAt
+//   - 1': the accounts of the tests, GetTEoa, {test} being the hash of the test name
+//   - 2': the named accounts, Account, {index} being the hash of the name
+var (
+	testRootPath  = accounts.DerivationPath{0x80000000 + 44, 0x80000000 + 60, 0x80000000 + 1}
+	namedRootPath = accounts.DerivationPath{0x80000000 + 44, 0x80000000 + 60, 0x80000000 + 2, 0}
+)
+
+var (
+	eoaLock sync.Mutex
+	// eoaTCounts are the accounts GetTEoa allocated to the running tests.
+	eoaTCounts = make(map[*testing.T]uint32)
+)
+
 func GetTOwner(t *testing.T) *bind.TransactOpts {
-	account, err := wallet.Derive(accounts.DefaultBaseDerivationPath, true)
-	require.NoError(t, err)
-	pk, err := wallet.PrivateKey(account)
-	require.NoError(t, err)
-	opts, err := bind.NewKeyedTransactorWithChainID(pk, ChainID)
+	opts, err := GetEoaAt(ChainID, 0)
 	require.NoError(t, err)
 	return opts
 }
 
+// GetTEoa returns the next account of the test. The accounts depend on the name of the
+// test only, so they are the same on every run and tests running in parallel get
+// different ones.
 func GetTEoa(t *testing.T) *bind.TransactOpts {
-	eoaTCount++
-	account, err := wallet.Derive(append(accounts.DefaultRootDerivationPath, eoaTCount), true)
-	require.NoError(t, err)
-	pk, err := wallet.PrivateKey(account)
+	eoaLock.Lock()
+	index, ok := eoaTCounts[t]
+	eoaTCounts[t] = index + 1
+	eoaLock.Unlock()
+	if !ok {
+		t.Cleanup(func() {
+			eoaLock.Lock()
+			defer eoaLock.Unlock()
+			delete(eoaTCounts, t)
+		})
+	}
+
+	key, err := keyAt(append(testRootPath[:len(testRootPath):len(testRootPath)], nameIndex(t.Name()), index))
 	require.NoError(t, err)
-	opts, err := bind.NewKeyedTransactorWithChainID(pk, ChainID)
+	opts, err := bind.NewKeyedTransactorWithChainID(key, ChainID)
 	require.NoError(t, err)
 	return opts
 }
@@ -69,35 +210,92 @@ func GetTEoas(t *testing.T, count int) []*bind.TransactOpts {
 	return opts
 }
 
+// Account returns the account of the name, e.g. "alice", the same in every test.
+func Account(t *testing.T, name string) *bind.TransactOpts {
+	key, err := AccountKey(name)
+	require.NoError(t, err)
+	opts, err := bind.NewKeyedTransactorWithChainID(key, ChainID)
+	require.NoError(t, err)
+	return opts
+}
+
+// AccountKey returns the private key of the account of the name, see Account.
+func AccountKey(name string) (*ecdsa.PrivateKey, error) {
+	return keyAt(append(namedRootPath[:len(namedRootPath):len(namedRootPath)], nameIndex(name)))
+}
+
+// GetTKey returns the private key of an account of the wallet, e.g. of GetTEoa or
+// Account, to sign messages with, see bmsutils.SignMessage.
+func GetTKey(t *testing.T, opts *bind.TransactOpts) *ecdsa.PrivateKey {
+	key, err := bmsutils.KeyOf(opts)
+	require.NoError(t, err)
+	return key
+}
+
+// nameIndex hashes the name to a non-hardened index.
+func nameIndex(name string) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	return hash.Sum32() &^ 0x80000000
+}
+
 func GetEoaAt(chainID *big.Int, index uint32) (*bind.TransactOpts, error) {
-	if account, err := wallet.Derive(append(accounts.DefaultRootDerivationPath, index), true); err != nil {
-		return nil, err
-	} else if pk, err := wallet.PrivateKey(account); err != nil {
+	if pk, err := GetKeyAt(index); err != nil {
 		return nil, err
 	} else {
 		return bind.NewKeyedTransactorWithChainID(pk, chainID)
 	}
 }
 
-func GetEoa(chainID *big.Int) (*bind.TransactOpts, error) {
-	if account, err := wallet.Derive(append(accounts.DefaultRootDerivationPath, eoaCount), true); err != nil {
+// GetKeyAt returns the private key of the account of the wallet at index.
+func GetKeyAt(index uint32) (*ecdsa.PrivateKey, error) {
+	return keyAt(append(accounts.DefaultRootDerivationPath[:len(accounts.DefaultRootDerivationPath):len(accounts.DefaultRootDerivationPath)], index))
+}
+
+func keyAt(path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	wallet, err := getWallet()
+	if err != nil {
+		return nil, err
+	}
+	account, err := wallet.Derive(path, false)
+	if err != nil {
+		return nil, err
+	}
+	key, err := wallet.PrivateKey(account)
+	if err != nil {
 		return nil, err
-	} else if pk, err := wallet.PrivateKey(account); err != nil {
+	}
+	bmsutils.EnrollKey(key)
+	return key, nil
+}
+
+// GetEoa returns the next account of the backends and nodes, from the first one.
+func GetEoa(chainID *big.Int) (*bind.TransactOpts, error) {
+	eoaLock.Lock()
+	defer eoaLock.Unlock()
+	return getEoa(chainID)
+}
+
+func getEoa(chainID *big.Int) (*bind.TransactOpts, error) {
+	if opts, err := GetEoaAt(chainID, eoaCount); err != nil {
 		return nil, err
 	} else {
 		eoaCount++
-		return bind.NewKeyedTransactorWithChainID(pk, chainID)
+		return opts, nil
 	}
 }
 
 func GetEoas(chainID *big.Int, count int) ([]*bind.TransactOpts, error) {
+	eoaLock.Lock()
+	defer eoaLock.Unlock()
+
 	var (
 		opts []*bind.TransactOpts = make([]*bind.TransactOpts, count)
 		err  error                = nil
 	)
 	cIndex := eoaCount
 	for i := 0; i < count; i++ {
-		if opts[i], err = GetEoa(chainID); err != nil {
+		if opts[i], err = getEoa(chainID); err != nil {
 			eoaCount = cIndex
 			return nil, err
 		}
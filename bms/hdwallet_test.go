@@ -0,0 +1,107 @@
+package bms_test
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	hdwallet "github.com/miguelmota/go-ethereum-hdwallet"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWallet(t *testing.T) {
+	if os.Getenv(bms.MnemonicEnv) != "" || os.Getenv(bms.MnemonicFileEnv) != "" {
+		t.Skip("the mnemonic is configured by the environment")
+	}
+
+	// hardhat, anvil 과 같은 기본 계정
+	owner := bms.GetTOwner(t)
+	require.Equal(t, common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"), owner.From)
+	eoa, err := bms.GetEoaAt(bms.ChainID, 1)
+	require.NoError(t, err)
+	require.Equal(t, common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), eoa.From)
+
+	require.Error(t, bms.UseMnemonic("not a mnemonic"))
+	require.Equal(t, owner.From, bms.GetTOwner(t).From)
+
+	t.Cleanup(func() { require.NoError(t, bms.UseMnemonic(bms.DefaultMnemonic)) })
+	require.NoError(t, bms.UseMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"))
+	require.Equal(t, common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94"), bms.GetTOwner(t).From)
+}
+
+func TestTestEoas(t *testing.T) {
+	if os.Getenv(bms.MnemonicEnv) != "" || os.Getenv(bms.MnemonicFileEnv) != "" {
+		t.Skip("the mnemonic is configured by the environment")
+	}
+
+	// 계정은 테스트 이름으로만 정해진다: m/44'/60'/1'/{fnv32a(이름)}/{순서}
+	wallet, err := hdwallet.NewFromMnemonic(bms.DefaultMnemonic)
+	require.NoError(t, err)
+	hash := fnv.New32a()
+	hash.Write([]byte(t.Name()))
+	for i, eoa := range bms.GetTEoas(t, 2) {
+		account, err := wallet.Derive(hdwallet.MustParseDerivationPath(fmt.Sprintf("m/44'/60'/1'/%d/%d", hash.Sum32()&^0x80000000, i)), false)
+		require.NoError(t, err)
+		require.Equal(t, account.Address, eoa.From)
+	}
+
+	// 병렬 테스트는 서로 다른 계정을 받는다.
+	var lock sync.Mutex
+	seen := make(map[common.Address]string)
+	t.Run("parallel", func(t *testing.T) {
+		for _, name := range []string{"a", "b", "c", "d"} {
+			name := name
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				for _, eoa := range bms.GetTEoas(t, 8) {
+					lock.Lock()
+					other, ok := seen[eoa.From]
+					seen[eoa.From] = name
+					lock.Unlock()
+					require.False(t, ok, "shared with %s", other)
+				}
+			})
+		}
+	})
+	require.Len(t, seen, 32)
+}
+
+func TestAccount(t *testing.T) {
+	alice, bob := bms.Account(t, "alice"), bms.Account(t, "bob")
+	require.NotEqual(t, alice.From, bob.From)
+	t.Run("sub", func(t *testing.T) {
+		require.Equal(t, alice.From, bms.Account(t, "alice").From)
+	})
+
+	key, err := bms.AccountKey("alice")
+	require.NoError(t, err)
+	require.Equal(t, alice.From, crypto.PubkeyToAddress(key.PublicKey))
+}
+
+func TestMnemonicFile(t *testing.T) {
+	t.Setenv(bms.MnemonicEnv, "")
+	t.Setenv(bms.MnemonicFileEnv, "")
+
+	dir := t.TempDir()
+	t.Setenv(bms.ConfigFileEnv, filepath.Join(dir, "bms.json"))
+	file, err := bms.MnemonicFile()
+	require.NoError(t, err)
+	require.Empty(t, file)
+
+	// 설정 파일 기준 상대경로
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bms.json"), []byte(`{"wallet": {"mnemonicFile": "keys/mnemonic"}}`), 0644))
+	file, err = bms.MnemonicFile()
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "keys", "mnemonic"), file)
+
+	t.Setenv(bms.MnemonicFileEnv, "/tmp/mnemonic")
+	file, err = bms.MnemonicFile()
+	require.NoError(t, err)
+	require.Equal(t, "/tmp/mnemonic", file)
+}
@@ -0,0 +1,317 @@
+package bms
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// impersonation is the state an impersonated account had before the forwarder replaced
+// its code, restored once the transactions sent on its behalf are mined.
+type impersonation struct {
+	code    []byte
+	nonce   uint64
+	pending map[common.Hash]bool
+	sent    uint64
+}
+
+// Impersonate lets SendImpersonated send transactions on behalf of the account.
+func (ec *Backend) Impersonate(address common.Address) {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+	if ec.impersonated == nil {
+		ec.impersonated = make(map[common.Address]bool)
+	}
+	ec.impersonated[address] = true
+}
+
+// isImpersonated reports whether Impersonate was called for the account.
+func (ec *Backend) isImpersonated(address common.Address) bool {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+	return ec.impersonated[address]
+}
+
+// StopImpersonating undoes Impersonate.
+func (ec *Backend) StopImpersonating(address common.Address) {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+	delete(ec.impersonated, address)
+}
+
+// SendImpersonated sends a transaction on behalf of an impersonated account, nil to
+// creating a contract. A gas of 0 is estimated.
+//
+// The chain only accepts signed transactions, so the owner sends the transaction to
+// the account, whose code is replaced by a forwarder making the call or the creation
+// until the transaction is mined. The callee sees the account as msg.sender, but unlike
+// the impersonation of hardhat and anvil:
+//
+//   - the transaction, its tx.origin and its gas cost belong to the owner;
+//   - the account has code while the transaction runs, so checks like
+//     msg.sender.code.length == 0 or msg.sender == tx.origin fail;
+//   - calls back to the account, like onERC721Received of safeTransferFrom, reach the
+//     forwarder, which succeeds without output, so such callbacks revert;
+//   - setting the forwarder and restoring the code and the nonce of the account take
+//     an additional block each, before and after the transaction.
+//
+// The receipt of a creation has no contract address: the contract is at the address of
+// the account and its nonce, as if it sent the transaction.
+func (ec *Backend) SendImpersonated(ctx context.Context, from common.Address, to *common.Address, value *big.Int, data []byte, gas uint64) (*types.Transaction, error) {
+	if err := ec.forkLoadAccount(ctx, nil, from); err != nil {
+		return nil, err
+	}
+	if err := ec.setForwarder(from); err != nil {
+		return nil, err
+	}
+
+	input := forwarderInput(to, value, data)
+	if gas == 0 {
+		var err error
+		if gas, err = ec.EstimateGas(ctx, ethereum.CallMsg{From: ec.Owner.From, To: &from, Data: input}); err != nil {
+			ec.dropImpersonation(from, common.Hash{})
+			return nil, err
+		}
+	}
+	opts := *ec.Owner
+	opts.Value, opts.GasLimit = nil, gas
+	tx, err := bmsutils.CreateDynamicTx(ec, &opts, &from, input)
+	if err != nil {
+		ec.dropImpersonation(from, common.Hash{})
+		return nil, err
+	}
+
+	ec.commitLock.Lock()
+	ec.impersonations[from].pending[tx.Hash()] = true
+	ec.commitLock.Unlock()
+	if err := ec.SendTransaction(ctx, tx); err != nil {
+		if receipt, _ := ec.TransactionReceipt(ctx, tx.Hash()); receipt == nil {
+			ec.dropImpersonation(from, tx.Hash())
+		}
+		return tx, err
+	}
+	return tx, nil
+}
+
+// setForwarder replaces the code of the impersonated account with the forwarder, unless
+// it is already there.
+func (ec *Backend) setForwarder(address common.Address) error {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	if !ec.impersonated[address] {
+		return fmt.Errorf("%s is not impersonated", address.Hex())
+	}
+	if ec.impersonations == nil {
+		ec.impersonations = make(map[common.Address]*impersonation)
+	}
+	if ec.impersonations[address] != nil {
+		ec.impersonations[address].sent++
+		return nil
+	}
+
+	head := ec.eth.BlockChain().CurrentBlock()
+	statedb, err := ec.eth.BlockChain().StateAt(head.Root)
+	if err != nil {
+		return err
+	}
+	ec.impersonations[address] = &impersonation{
+		code:    statedb.GetCode(address),
+		nonce:   statedb.GetNonce(address),
+		pending: make(map[common.Hash]bool),
+		sent:    1,
+	}
+	statedb.SetCode(address, forwarderCode(ec.Owner.From))
+	return ec.writeState(head, statedb)
+}
+
+// dropImpersonation forgets a transaction that was not sent, restoring the account if
+// nothing else is pending.
+func (ec *Backend) dropImpersonation(address common.Address, txHash common.Hash) {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	if dropped := ec.impersonations[address]; dropped != nil {
+		delete(dropped.pending, txHash)
+		dropped.sent--
+		if len(dropped.pending) == 0 {
+			if err := ec.restore(map[common.Address]*impersonation{address: dropped}); err != nil {
+				log.Warn("Failed to restore impersonated account", "address", address, "err", err)
+			}
+		}
+	}
+}
+
+// restoreImpersonated restores the accounts whose transactions are all mined once the
+// block is. Expects commitLock to be held.
+func (ec *Backend) restoreImpersonated(blockHash common.Hash) error {
+	if len(ec.impersonations) == 0 {
+		return nil
+	}
+	block := ec.eth.BlockChain().GetBlockByHash(blockHash)
+	if block == nil {
+		return nil
+	}
+
+	done := make(map[common.Address]*impersonation)
+	for address, impersonation := range ec.impersonations {
+		for _, tx := range block.Transactions() {
+			delete(impersonation.pending, tx.Hash())
+		}
+		if len(impersonation.pending) == 0 {
+			done[address] = impersonation
+		}
+	}
+	return ec.restore(done)
+}
+
+// copyImpersonations copies the impersonations, e.g. for a snapshot.
+func copyImpersonations(impersonations map[common.Address]*impersonation) map[common.Address]*impersonation {
+	copied := make(map[common.Address]*impersonation, len(impersonations))
+	for address, original := range impersonations {
+		pending := make(map[common.Hash]bool, len(original.pending))
+		for hash := range original.pending {
+			pending[hash] = true
+		}
+		copied[address] = &impersonation{code: original.code, nonce: original.nonce, pending: pending, sent: original.sent}
+	}
+	return copied
+}
+
+// dropImpersonations forgets the transactions sent on behalf of the impersonated accounts
+// that are not mined, after Rollback or Revert dropped them, and restores the accounts
+// the latest state still has the forwarder of. Expects commitLock to be held.
+func (ec *Backend) dropImpersonations() error {
+	if len(ec.impersonations) == 0 {
+		return nil
+	}
+	head := ec.eth.BlockChain().CurrentBlock()
+	statedb, err := ec.eth.BlockChain().StateAt(head.Root)
+	if err != nil {
+		return err
+	}
+	forwarder := forwarderCode(ec.Owner.From)
+	restored := make(map[common.Address]*impersonation)
+	for address, impersonation := range ec.impersonations {
+		impersonation.sent -= uint64(len(impersonation.pending))
+		if bytes.Equal(statedb.GetCode(address), forwarder) {
+			restored[address] = impersonation
+		}
+	}
+	ec.impersonations = nil
+	return ec.restore(restored)
+}
+
+// restore writes back the code of the accounts and bumps their nonce by the number of
+// transactions sent on their behalf. Expects commitLock to be held.
+func (ec *Backend) restore(impersonations map[common.Address]*impersonation) error {
+	if len(impersonations) == 0 {
+		return nil
+	}
+	head := ec.eth.BlockChain().CurrentBlock()
+	statedb, err := ec.eth.BlockChain().StateAt(head.Root)
+	if err != nil {
+		return err
+	}
+	for address, impersonation := range impersonations {
+		statedb.SetCode(address, impersonation.code)
+		statedb.SetNonce(address, impersonation.nonce+impersonation.sent)
+		delete(ec.impersonations, address)
+	}
+	return ec.writeState(head, statedb)
+}
+
+// forwarderInput encodes the call, or the creation if to is nil, for the forwarder:
+// a kind byte (0 call, 1 creation), the value as 32 bytes, the callee and the data.
+func forwarderInput(to *common.Address, value *big.Int, data []byte) []byte {
+	input := make([]byte, 33)
+	if value != nil {
+		value.FillBytes(input[1:33])
+	}
+	if to == nil {
+		input[0] = 1
+		return append(input, data...)
+	}
+	return append(append(input, to.Bytes()...), data...)
+}
+
+// forwarderCode returns the code impersonated accounts run while a transaction is sent on
+// their behalf. Called by the relayer with the input of forwarderInput, it makes the call
+// or the creation and returns its output (the created address), or reverts with it.
+// Calls from anyone else do nothing.
+func forwarderCode(relayer common.Address) []byte {
+	return assemble(
+		vm.CALLER, vm.PUSH20, relayer.Bytes(), vm.EQ, jumpTo("main"), vm.JUMPI, vm.STOP,
+		label("main"),
+		vm.PUSH1, []byte{0}, vm.CALLDATALOAD, vm.PUSH1, []byte{0xf8}, vm.SHR, jumpTo("create"), vm.JUMPI,
+		// call(gas, to, value, 0, size, 0, 0) with the data copied at 0
+		vm.PUSH1, []byte{53}, vm.CALLDATASIZE, vm.SUB,
+		dup1, vm.PUSH1, []byte{53}, vm.PUSH1, []byte{0}, vm.CALLDATACOPY,
+		vm.PUSH1, []byte{0}, vm.PUSH1, []byte{0}, dup3, vm.PUSH1, []byte{0},
+		vm.PUSH1, []byte{1}, vm.CALLDATALOAD,
+		vm.PUSH1, []byte{33}, vm.CALLDATALOAD, vm.PUSH1, []byte{0x60}, vm.SHR,
+		vm.GAS, vm.CALL,
+		vm.RETURNDATASIZE, vm.PUSH1, []byte{0}, vm.PUSH1, []byte{0}, vm.RETURNDATACOPY,
+		jumpTo("returned"), vm.JUMPI,
+		vm.RETURNDATASIZE, vm.PUSH1, []byte{0}, vm.REVERT,
+		label("returned"),
+		vm.RETURNDATASIZE, vm.PUSH1, []byte{0}, vm.RETURN,
+		// create(value, 0, size) with the init code copied at 0
+		label("create"),
+		vm.PUSH1, []byte{33}, vm.CALLDATASIZE, vm.SUB,
+		dup1, vm.PUSH1, []byte{33}, vm.PUSH1, []byte{0}, vm.CALLDATACOPY,
+		vm.PUSH1, []byte{0}, vm.PUSH1, []byte{1}, vm.CALLDATALOAD, vm.CREATE,
+		dup1, jumpTo("created"), vm.JUMPI,
+		vm.RETURNDATASIZE, vm.PUSH1, []byte{0}, vm.PUSH1, []byte{0}, vm.RETURNDATACOPY,
+		vm.RETURNDATASIZE, vm.PUSH1, []byte{0}, vm.REVERT,
+		label("created"),
+		vm.PUSH1, []byte{0}, vm.MSTORE, vm.PUSH1, []byte{32}, vm.PUSH1, []byte{0}, vm.RETURN,
+	)
+}
+
+// the DUP opcodes are untyped constants in the vm package
+const (
+	dup1 = vm.OpCode(vm.DUP1)
+	dup3 = vm.OpCode(vm.DUP3)
+)
+
+type (
+	label  string // a JUMPDEST
+	jumpTo string // a PUSH2 of the position of the label
+)
+
+// assemble concatenates opcodes and push data, resolving labels.
+func assemble(items ...interface{}) []byte {
+	code := make([]byte, 0)
+	labels := make(map[label]int)
+	jumps := make(map[int]label)
+	for _, item := range items {
+		switch item := item.(type) {
+		case vm.OpCode:
+			code = append(code, byte(item))
+		case []byte:
+			code = append(code, item...)
+		case label:
+			labels[item] = len(code)
+			code = append(code, byte(vm.JUMPDEST))
+		case jumpTo:
+			jumps[len(code)+1] = label(item)
+			code = append(code, byte(vm.PUSH2), 0, 0)
+		default:
+			panic(fmt.Sprintf("assemble: unexpected %T", item))
+		}
+	}
+	for position, label := range jumps {
+		binary.BigEndian.PutUint16(code[position:], uint16(labels[label]))
+	}
+	return code
+}
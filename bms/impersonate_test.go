@@ -0,0 +1,116 @@
+package bms_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/stretchr/testify/require"
+)
+
+// probeCode stores what the callee of an impersonated account sees: the code size of
+// msg.sender at slot 0, tx.origin at 1, the block number at 2, and at 3 and 4 the success
+// and the size of the output of onERC721Received called on msg.sender.
+func probeCode() []byte {
+	return program(
+		op(vm.CALLER), op(vm.EXTCODESIZE), push([]byte{0}), op(vm.SSTORE),
+		op(vm.ORIGIN), push([]byte{1}), op(vm.SSTORE),
+		op(vm.NUMBER), push([]byte{2}), op(vm.SSTORE),
+		push([]byte{0x15, 0x0b, 0x7a, 0x02}), push([]byte{0xe0}), op(vm.SHL), push([]byte{0}), op(vm.MSTORE),
+		push([]byte{0}), push([]byte{0}), push([]byte{4}), push([]byte{0}), push([]byte{0}), op(vm.CALLER), op(vm.GAS), op(vm.CALL),
+		push([]byte{3}), op(vm.SSTORE),
+		op(vm.RETURNDATASIZE), push([]byte{4}), op(vm.SSTORE),
+		op(vm.STOP),
+	)
+}
+
+// TestImpersonationDifferences pins where SendImpersonated differs from hardhat and anvil.
+func TestImpersonationDifferences(t *testing.T) {
+	backend := bms.NewBacked(t)
+	ctx := context.Background()
+
+	account := bms.GetTEoa(t).From
+	require.NoError(t, backend.SetBalance(account, bmsutils.ToWei(1)))
+	probe := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	require.NoError(t, backend.SetCode(probe, probeCode()))
+	backend.Impersonate(account)
+
+	before, err := backend.BlockNumber(ctx)
+	require.NoError(t, err)
+	tx, err := backend.SendImpersonated(ctx, account, &probe, nil, nil, 0)
+	require.NoError(t, err)
+	receipt, err := backend.TransactionReceipt(ctx, tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+
+	// the owner signs the transaction, sent to the account
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	require.NoError(t, err)
+	require.Equal(t, backend.Owner.From, sender)
+	require.Equal(t, account, *tx.To())
+
+	slot := func(index int64) *big.Int {
+		value, err := backend.StorageAt(ctx, probe, common.BigToHash(big.NewInt(index)), nil)
+		require.NoError(t, err)
+		return new(big.Int).SetBytes(value)
+	}
+	// msg.sender has the code of the forwarder
+	require.NotZero(t, slot(0).Sign())
+	// tx.origin is the owner
+	require.Equal(t, backend.Owner.From, common.BigToAddress(slot(1)))
+	// setting the forwarder and restoring the account take a block each
+	require.Equal(t, before+2, receipt.BlockNumber.Uint64())
+	require.Equal(t, before+2, slot(2).Uint64())
+	head, err := backend.BlockNumber(ctx)
+	require.NoError(t, err)
+	require.Equal(t, before+3, head)
+	// callbacks on msg.sender, like onERC721Received, succeed with no output
+	require.Equal(t, int64(1), slot(3).Int64())
+	require.Zero(t, slot(4).Sign())
+
+	code, err := backend.CodeAt(ctx, account, nil)
+	require.NoError(t, err)
+	require.Empty(t, code)
+}
+
+func TestImpersonationDropped(t *testing.T) {
+	backend := bms.NewBacked(t)
+	ctx := context.Background()
+	backend.SetAutomine(false)
+
+	account := common.HexToAddress("0x00000000000000000000000000000000000000dd")
+	original := program(op(vm.STOP))
+	require.NoError(t, backend.SetCode(account, original))
+	require.NoError(t, backend.SetNonce(account, 3))
+	probe := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	require.NoError(t, backend.SetCode(probe, probeCode()))
+	backend.Impersonate(account)
+
+	requireRestored := func() {
+		code, err := backend.CodeAt(ctx, account, nil)
+		require.NoError(t, err)
+		require.Equal(t, original, code)
+		nonce, err := backend.NonceAt(ctx, account, nil)
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), nonce)
+	}
+
+	// the transaction is pending, so the account has the code of the forwarder
+	_, err := backend.SendImpersonated(ctx, account, &probe, nil, nil, 100000)
+	require.NoError(t, err)
+	backend.Rollback()
+	requireRestored()
+
+	// the snapshot has the forwarder
+	_, err = backend.SendImpersonated(ctx, account, &probe, nil, nil, 100000)
+	require.NoError(t, err)
+	id := backend.Snapshot()
+	backend.Commit()
+	require.NoError(t, backend.Revert(id))
+	requireRestored()
+}
@@ -0,0 +1,189 @@
+package bms
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/beacon/engine"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/miner"
+)
+
+// MiningMode decides when the Backend seals a new block.
+type MiningMode uint8
+
+const (
+	// AutoMine seals a block for every transaction sent through the Backend.
+	AutoMine MiningMode = iota
+	// ManualMine seals blocks only on Commit or Mine.
+	ManualMine
+	// IntervalMine seals a block on every tick of the mining interval.
+	IntervalMine
+)
+
+func (mode MiningMode) String() string {
+	switch mode {
+	case AutoMine:
+		return "automine"
+	case ManualMine:
+		return "manual"
+	case IntervalMine:
+		return "interval"
+	default:
+		return "unknown"
+	}
+}
+
+// MiningMode returns the current mining mode of the backend.
+func (ec *Backend) MiningMode() MiningMode {
+	ec.miningLock.Lock()
+	defer ec.miningLock.Unlock()
+	return ec.miningMode
+}
+
+// SetAutomine switches between AutoMine and ManualMine, stopping interval mining if it is running.
+func (ec *Backend) SetAutomine(enable bool) {
+	ec.miningLock.Lock()
+	defer ec.miningLock.Unlock()
+
+	ec.stopInterval()
+	if enable {
+		ec.miningMode = AutoMine
+	} else {
+		ec.miningMode = ManualMine
+	}
+}
+
+// SetIntervalMining seals a block every interval, whether or not it contains transactions.
+// A zero interval falls back to ManualMine.
+func (ec *Backend) SetIntervalMining(interval time.Duration) {
+	ec.miningLock.Lock()
+	defer ec.miningLock.Unlock()
+
+	ec.stopInterval()
+	if interval <= 0 {
+		ec.miningMode = ManualMine
+		return
+	}
+	ec.miningMode = IntervalMine
+	ec.intervalStop = make(chan struct{})
+	ec.intervalDone = make(chan struct{})
+	go ec.intervalLoop(interval, ec.intervalStop, ec.intervalDone)
+}
+
+// Mine seals the given number of blocks and returns the hash of the last one.
+func (ec *Backend) Mine(blocks int) common.Hash {
+	var hash common.Hash
+	for i := 0; i < blocks; i++ {
+		hash = ec.Commit()
+	}
+	return hash
+}
+
+// stopInterval stops interval mining and waits for the block being sealed, if any.
+// Expects the mining lock to be held.
+func (ec *Backend) stopInterval() {
+	if ec.intervalStop != nil {
+		close(ec.intervalStop)
+		<-ec.intervalDone
+		ec.intervalStop = nil
+		ec.intervalDone = nil
+	}
+}
+
+func (ec *Backend) intervalLoop(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ec.Commit()
+		}
+	}
+}
+
+// SetNextBlockTimestamp sets the timestamp of the next block, which must be after the
+// latest one. The clock of the chain continues from there.
+func (ec *Backend) SetNextBlockTimestamp(timestamp uint64) error {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	if head := ec.eth.BlockChain().CurrentBlock(); timestamp <= head.Time {
+		return fmt.Errorf("timestamp %d is not after the latest block timestamp %d", timestamp, head.Time)
+	}
+	ec.nextTimestamp = timestamp
+	return nil
+}
+
+// IncreaseTime moves the clock of the chain forward for the next blocks and returns the
+// total time added so far.
+func (ec *Backend) IncreaseTime(adjustment time.Duration) time.Duration {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	ec.timeOffset += int64(adjustment / time.Second)
+	return time.Duration(ec.timeOffset) * time.Second
+}
+
+// timestamp returns the timestamp of the next block. Expects commitLock to be held.
+func (ec *Backend) timestamp() uint64 {
+	now := time.Now().Unix()
+	if ec.nextTimestamp != 0 {
+		timestamp := ec.nextTimestamp
+		ec.nextTimestamp = 0
+		ec.timeOffset = int64(timestamp) - now
+		return timestamp
+	}
+	return uint64(now + ec.timeOffset)
+}
+
+// seal builds a block with the pending transactions on top of the latest one and makes
+// it the head, the way the simulated beacon does but at the given timestamp. Expects
+// commitLock to be held.
+func (ec *Backend) seal(timestamp uint64) (common.Hash, error) {
+	chain := ec.eth.BlockChain()
+	parent := chain.CurrentBlock()
+	if timestamp <= parent.Time {
+		timestamp = parent.Time + 1
+	}
+
+	// SendTx adds to the pool asynchronously, wait for it to promote the transactions
+	if err := ec.eth.TxPool().Sync(); err != nil {
+		return common.Hash{}, err
+	}
+
+	var random common.Hash
+	rand.Read(random[:])
+	payload, err := ec.eth.Miner().BuildPayload(&miner.BuildPayloadArgs{
+		Parent:      parent.Hash(),
+		Timestamp:   timestamp,
+		Random:      random,
+		Withdrawals: make(types.Withdrawals, 0),
+		Version:     engine.PayloadV2,
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+	envelope := payload.ResolveFull()
+	if envelope == nil {
+		return common.Hash{}, fmt.Errorf("payload of block %d was not built", parent.Number.Uint64()+1)
+	}
+	block, err := engine.ExecutableDataToBlock(*envelope.ExecutionPayload, nil, nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := chain.InsertBlockWithoutSetHead(block); err != nil {
+		return common.Hash{}, err
+	}
+	if _, err := chain.SetCanonical(block); err != nil {
+		return common.Hash{}, err
+	}
+	chain.SetSafe(block.Header())
+	chain.SetFinalized(block.Header())
+	return block.Hash(), nil
+}
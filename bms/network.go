@@ -0,0 +1,279 @@
+package bms
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	hdwallet "github.com/miguelmota/go-ethereum-hdwallet"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// ConfigFileEnv is the project config of the script, set by bms run and bms deploy.
+	ConfigFileEnv string = "BMS_CONFIG"
+
+	DefaultConfigFile string = "bms.json"
+)
+
+// Config is the project config, bms.json at the root of the project:
+//
+//	{
+//		"networks": {
+//			"sepolia": {
+//				"url": "https://rpc.sepolia.org",
+//				"chainId": 11155111,
+//				"gas": {"maxFeePerGas": "30", "maxPriorityFeePerGas": "1.5"},
+//				"confirmations": 2,
+//				"signer": {"keystore": "keys/deployer.json", "passwordEnv": "DEPLOYER_PASSWORD"}
+//			}
+//		}
+//	}
+//
+// Secrets are never written in the config, signers read them from the environment.
+type Config struct {
+	Networks map[string]*Network `json:"networks"`
+	Wallet   Wallet              `json:"wallet"`
+}
+
+// Wallet is the mnemonic the test, backend and node accounts are derived from, unless
+// MnemonicEnv or MnemonicFileEnv is set. It defaults to DefaultMnemonic, and is meant for
+// development accounts only.
+type Wallet struct {
+	Mnemonic string `json:"mnemonic,omitempty"`
+	// MnemonicFile is relative to the config, ~ being the home directory.
+	MnemonicFile string `json:"mnemonicFile,omitempty"`
+}
+
+// ReadConfig reads the project config at path. A missing file is an empty config.
+func ReadConfig(path string) (*Config, error) {
+	conf := &Config{Networks: make(map[string]*Network)}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return conf, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, conf); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	for name, network := range conf.Networks {
+		if network == nil {
+			return nil, fmt.Errorf("%s: network %q is empty", path, name)
+		}
+		network.Name = name
+		network.Signer.dir = filepath.Dir(path)
+	}
+	return conf, nil
+}
+
+// Network returns the profile of the network. The localhost network, a local bms node
+// signed for by the wallet, does not have to be configured.
+func (conf *Config) Network(name string) (*Network, error) {
+	if network, ok := conf.Networks[name]; ok {
+		return network, nil
+	}
+	if name == DefaultNetwork {
+		return &Network{Name: DefaultNetwork, URL: DefaultRPCURL, local: true}, nil
+	}
+	if len(conf.Networks) == 0 {
+		return nil, fmt.Errorf("unknown network %q, none is configured", name)
+	}
+	names := make([]string, 0, len(conf.Networks))
+	for name := range conf.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown network %q, configured: %s", name, strings.Join(names, ", "))
+}
+
+// Network is the profile of a network transactions are sent to.
+type Network struct {
+	Name string `json:"-"`
+	URL  string `json:"url"`
+	// ChainID is checked against the one of the endpoint, unless 0.
+	ChainID uint64 `json:"chainId"`
+	Gas     Gas    `json:"gas"`
+	// Confirmations is the number of blocks, the one of the transaction included, to wait
+	// for after a deployment.
+	Confirmations uint64 `json:"confirmations"`
+	Signer        Signer `json:"signer"`
+
+	// local is the built-in localhost network, whose signers are the wallet accounts.
+	local bool
+}
+
+// Gas is the gas strategy of a network. Unset values are suggested by the endpoint and
+// estimated per transaction, as bmsutils.CreateDynamicTx does.
+type Gas struct {
+	// MaxFeePerGas and MaxPriorityFeePerGas are in gwei, e.g. "1.5".
+	MaxFeePerGas         string `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas"`
+	GasLimit             uint64 `json:"gasLimit"`
+}
+
+// Signer is the source of the key of a network, one of:
+//   - keystore: an encrypted key file, its password in the variable passwordEnv
+//   - privateKeyEnv: the variable holding a hex private key
+//   - mnemonicEnv and path: the variable holding a mnemonic and the derivation path
+//
+// The path defaults to the first account. Only the built-in localhost network, not
+// configured in bms.json, may leave the signer empty, signing with the first account of
+// the wallet (see UseMnemonic) like the owner of the bms backends and nodes.
+type Signer struct {
+	// Keystore is relative to the config, ~ being the home directory.
+	Keystore      string `json:"keystore,omitempty"`
+	PasswordEnv   string `json:"passwordEnv,omitempty"`
+	PrivateKeyEnv string `json:"privateKeyEnv,omitempty"`
+	MnemonicEnv   string `json:"mnemonicEnv,omitempty"`
+	Path          string `json:"path,omitempty"`
+
+	// dir is the directory of the config the signer was read from.
+	dir string
+}
+
+// Dial connects to the network and returns its chain id.
+func (network *Network) Dial(ctx context.Context) (*ethclient.Client, *big.Int, error) {
+	client, err := ethclient.DialContext(ctx, network.URL)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, network.URL)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		client.Close()
+		return nil, nil, errors.Wrap(err, network.URL)
+	}
+	if network.ChainID != 0 && chainID.Uint64() != network.ChainID {
+		client.Close()
+		return nil, nil, fmt.Errorf("network %s expects chain id %d, %s serves %v", network.Name, network.ChainID, network.URL, chainID)
+	}
+	return client, chainID, nil
+}
+
+// TransactOpts returns the options signing with the key of the network for the chain,
+// with the fees and gas limit of its gas strategy.
+func (network *Network) TransactOpts(chainID *big.Int) (*bind.TransactOpts, error) {
+	key, err := network.Signer.key(network.local)
+	if err != nil {
+		return nil, errors.Wrapf(err, "network %s signer", network.Name)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(key, chainID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.GasFeeCap, err = gwei(network.Gas.MaxFeePerGas); err != nil {
+		return nil, errors.Wrapf(err, "network %s maxFeePerGas", network.Name)
+	}
+	if opts.GasTipCap, err = gwei(network.Gas.MaxPriorityFeePerGas); err != nil {
+		return nil, errors.Wrapf(err, "network %s maxPriorityFeePerGas", network.Name)
+	}
+	opts.GasLimit = network.Gas.GasLimit
+	return opts, nil
+}
+
+// key returns the key of the signer. An empty signer is the wallet on the local network.
+func (signer *Signer) key(local bool) (*ecdsa.PrivateKey, error) {
+	switch {
+	case signer.Keystore != "":
+		if signer.PasswordEnv == "" {
+			return nil, errors.New("keystore requires passwordEnv")
+		}
+		password, ok := os.LookupEnv(signer.PasswordEnv)
+		if !ok {
+			return nil, fmt.Errorf("%s is not set", signer.PasswordEnv)
+		}
+		keyJSON, err := os.ReadFile(signer.keystorePath())
+		if err != nil {
+			return nil, err
+		}
+		key, err := keystore.DecryptKey(keyJSON, password)
+		if err != nil {
+			return nil, errors.Wrap(err, signer.Keystore)
+		}
+		return key.PrivateKey, nil
+
+	case signer.PrivateKeyEnv != "":
+		hex := os.Getenv(signer.PrivateKeyEnv)
+		if hex == "" {
+			return nil, fmt.Errorf("%s is not set", signer.PrivateKeyEnv)
+		}
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(hex, "0x"))
+		if err != nil {
+			return nil, errors.Wrap(err, signer.PrivateKeyEnv)
+		}
+		return key, nil
+
+	case signer.MnemonicEnv != "":
+		mnemonic := os.Getenv(signer.MnemonicEnv)
+		if mnemonic == "" {
+			return nil, fmt.Errorf("%s is not set", signer.MnemonicEnv)
+		}
+		source, err := hdwallet.NewFromMnemonic(strings.TrimSpace(mnemonic))
+		if err != nil {
+			return nil, errors.Wrap(err, signer.MnemonicEnv)
+		}
+		return signer.derive(source)
+
+	case local:
+		source, err := getWallet()
+		if err != nil {
+			return nil, err
+		}
+		return signer.derive(source)
+
+	default:
+		return nil, errors.New("no signer is configured, set keystore, privateKeyEnv or mnemonicEnv")
+	}
+}
+
+// keystorePath returns the keystore file, relative to the directory of the config.
+func (signer *Signer) keystorePath() string {
+	path := expandHome(signer.Keystore)
+	if !filepath.IsAbs(path) && signer.dir != "" {
+		path = filepath.Join(signer.dir, path)
+	}
+	return path
+}
+
+// derive returns the key of the path of the signer in the wallet.
+func (signer *Signer) derive(source *hdwallet.Wallet) (*ecdsa.PrivateKey, error) {
+	path := accounts.DefaultBaseDerivationPath
+	if signer.Path != "" {
+		var err error
+		if path, err = hdwallet.ParseDerivationPath(signer.Path); err != nil {
+			return nil, err
+		}
+	}
+	account, err := source.Derive(path, false)
+	if err != nil {
+		return nil, err
+	}
+	return source.PrivateKey(account)
+}
+
+// gwei converts a decimal amount of gwei to wei, nil if empty.
+func gwei(amount string) (*big.Int, error) {
+	if amount == "" {
+		return nil, nil
+	}
+	value, err := decimal.NewFromString(amount)
+	if err != nil {
+		return nil, err
+	}
+	if value.IsNegative() {
+		return nil, fmt.Errorf("negative amount %s", amount)
+	}
+	return value.Shift(9).BigInt(), nil
+}
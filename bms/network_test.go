@@ -0,0 +1,101 @@
+package bms_test
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetwork(t *testing.T) {
+	backend := bms.NewBacked(t, bms.WithHTTP("127.0.0.1", 0))
+	dir := t.TempDir()
+
+	store, err := keystore.StoreKey(dir, "secret", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	t.Setenv("TEST_PASSWORD", "secret")
+	t.Setenv("TEST_PRIVATE_KEY", "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	t.Setenv("TEST_MNEMONIC", "test test test test test test test test test test test junk")
+
+	file := filepath.Join(dir, "bms.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{
+		"networks": {
+			"local": {
+				"url": "`+backend.HTTPEndpoint()+`",
+				"chainId": 1337,
+				"gas": {"maxFeePerGas": "1.5", "maxPriorityFeePerGas": "0.1", "gasLimit": 100000},
+				"confirmations": 2,
+				"signer": {"keystore": "`+store.URL.Path+`", "passwordEnv": "TEST_PASSWORD"}
+			},
+			"relative": {"signer": {"keystore": "`+filepath.Base(store.URL.Path)+`", "passwordEnv": "TEST_PASSWORD"}},
+			"wrong": {"url": "`+backend.HTTPEndpoint()+`", "chainId": 1},
+			"key": {"signer": {"privateKeyEnv": "TEST_PRIVATE_KEY"}},
+			"unsigned": {"url": "`+backend.HTTPEndpoint()+`"},
+			"mnemonic": {"signer": {"mnemonicEnv": "TEST_MNEMONIC", "path": "m/44'/60'/0'/0/1"}}
+		}
+	}`), 0644))
+
+	conf, err := bms.ReadConfig(file)
+	require.NoError(t, err)
+	_, err = conf.Network("unknown")
+	require.Error(t, err)
+
+	// localhost is a local node signed for by the wallet
+	localhost, err := conf.Network(bms.DefaultNetwork)
+	require.NoError(t, err)
+	require.Equal(t, bms.DefaultRPCURL, localhost.URL)
+	opts, err := localhost.TransactOpts(bms.ChainID)
+	require.NoError(t, err)
+	require.Equal(t, backend.Owner.From, opts.From)
+
+	local, err := conf.Network("local")
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), local.Confirmations)
+	client, chainID, err := local.Dial(context.Background())
+	require.NoError(t, err)
+	client.Close()
+	require.Equal(t, bms.ChainID, chainID)
+	opts, err = local.TransactOpts(chainID)
+	require.NoError(t, err)
+	require.Equal(t, store.Address, opts.From)
+	require.Equal(t, big.NewInt(1.5*params.GWei), opts.GasFeeCap)
+	require.Equal(t, big.NewInt(0.1*params.GWei), opts.GasTipCap)
+	require.Equal(t, uint64(100000), opts.GasLimit)
+
+	// the keystore is relative to the config, not to the working directory
+	relative, err := conf.Network("relative")
+	require.NoError(t, err)
+	opts, err = relative.TransactOpts(chainID)
+	require.NoError(t, err)
+	require.Equal(t, store.Address, opts.From)
+
+	wrong, err := conf.Network("wrong")
+	require.NoError(t, err)
+	_, _, err = wrong.Dial(context.Background())
+	require.Error(t, err)
+
+	key, err := conf.Network("key")
+	require.NoError(t, err)
+	opts, err = key.TransactOpts(chainID)
+	require.NoError(t, err)
+	require.Equal(t, common.HexToAddress("0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"), opts.From)
+
+	// only localhost falls back to the wallet
+	unsigned, err := conf.Network("unsigned")
+	require.NoError(t, err)
+	_, err = unsigned.TransactOpts(chainID)
+	require.ErrorContains(t, err, "no signer is configured")
+
+	mnemonic, err := conf.Network("mnemonic")
+	require.NoError(t, err)
+	opts, err = mnemonic.TransactOpts(chainID)
+	require.NoError(t, err)
+	require.Equal(t, common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), opts.From)
+}
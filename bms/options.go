@@ -0,0 +1,189 @@
+package bms
+
+import (
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// Option configures the Backend created by NewBacked.
+type Option func(*config)
+
+type config struct {
+	eip1559        bool
+	tracing        bool
+	console        bool
+	callConsole    bool
+	gasReporter    *GasReporter
+	miningMode     MiningMode
+	miningInterval time.Duration
+	forkURL        string
+	forkBlock      *big.Int
+	forkCache      string
+	http           bool
+	httpHost       string
+	httpPort       int
+	ws             bool
+	wsHost         string
+	wsPort         int
+	alloc          core.GenesisAlloc
+	logger         Logger
+	signers        []*bind.TransactOpts
+	dataDir        string
+}
+
+func newConfig(options ...Option) *config {
+	conf := &config{gasReporter: gasReporter, forkCache: defaultForkCacheDir(), tracing: os.Getenv(TracingEnv) != ""}
+	for _, option := range options {
+		option(conf)
+	}
+	return conf
+}
+
+// WithEIP1559 starts the chain with the initial EIP-1559 base fee and lets it evolve
+// per block. Gas tips are suggested by the node and senders pay for the gas they use,
+// so balances reflect gas costs (see bmsutils.GasCost).
+func WithEIP1559() Option {
+	return func(conf *config) {
+		conf.eip1559 = true
+	}
+}
+
+// WithManualMining starts the backend in ManualMine mode, so blocks are sealed only on
+// Commit or Mine.
+func WithManualMining() Option {
+	return func(conf *config) {
+		conf.miningMode = ManualMine
+	}
+}
+
+// WithIntervalMining starts the backend in IntervalMine mode with the given interval.
+func WithIntervalMining(interval time.Duration) Option {
+	return func(conf *config) {
+		conf.miningMode = IntervalMine
+		conf.miningInterval = interval
+	}
+}
+
+// TracingEnv enables WithTracing on all backends when set, e.g. by bms test --trace.
+const TracingEnv string = "BMS_TRACE"
+
+// WithTracing runs the call tracer on failed transactions and gas estimations, so their
+// errors carry the decoded call tree (see bmsutils.TraceError). Calls are decoded with
+// the ABIs enrolled by bmsutils.EnrollErrors and Contract.SetABI.
+func WithTracing() Option {
+	return func(conf *config) {
+		conf.tracing = true
+	}
+}
+
+// WithConsoleLog prints the messages logged with hardhat's console.sol by the mined
+// transactions to the log of the test.
+func WithConsoleLog() Option {
+	return func(conf *config) {
+		conf.console = true
+	}
+}
+
+// WithCallConsoleLog prints console.sol messages of CallContract and EstimateGas too,
+// in addition to the mined transactions. Gas estimations made while sending a
+// transaction print the messages once more.
+func WithCallConsoleLog() Option {
+	return func(conf *config) {
+		conf.console = true
+		conf.callConsole = true
+	}
+}
+
+// WithGasReporter records the gas used by the transactions mined on the backend into
+// reporter. Backends record into the reporter of GasReportMain by default.
+func WithGasReporter(reporter *GasReporter) Option {
+	return func(conf *config) {
+		conf.gasReporter = reporter
+	}
+}
+
+// WithFork forks the state of the chain served by the JSON-RPC endpoint at url at the
+// block, the latest one if blockNumber is nil. Accounts, code and storage are fetched
+// from the endpoint as the calls and transactions of the backend touch them, and new
+// blocks are built locally on top of them. Fetched state is cached on disk per chain
+// and block (see WithForkCache), so pin the block to reuse the cache across runs.
+//
+// The local chain keeps its own chain id, and numbers its blocks from 0 rather than from
+// the forked block + 1, starting from the timestamp of the forked block. Reads and calls
+// don't change the chain, they get the fetched state as state overrides. Transactions
+// and the setters of the state write the fetched state they touch in an empty block
+// first, so the block numbers move by one more.
+func WithFork(url string, blockNumber *big.Int) Option {
+	return func(conf *config) {
+		conf.forkURL = url
+		conf.forkBlock = blockNumber
+	}
+}
+
+// WithForkCache sets the directory the state fetched by WithFork is cached in, the
+// user cache directory by default. An empty dir disables the cache.
+func WithForkCache(dir string) Option {
+	return func(conf *config) {
+		conf.forkCache = dir
+	}
+}
+
+// WithHTTP serves the JSON-RPC API of the backend over HTTP on host:port, port 0 picking
+// a free one (see Backend.HTTPEndpoint). Any origin is allowed.
+func WithHTTP(host string, port int) Option {
+	return func(conf *config) {
+		conf.http = true
+		conf.httpHost = host
+		conf.httpPort = port
+	}
+}
+
+// WithWS serves the JSON-RPC API of the backend over WebSocket on host:port, which can
+// be the same as the one of WithHTTP (see Backend.WSEndpoint). Any origin is allowed.
+func WithWS(host string, port int) Option {
+	return func(conf *config) {
+		conf.ws = true
+		conf.wsHost = host
+		conf.wsPort = port
+	}
+}
+
+// WithGenesisAlloc adds the accounts to the genesis of the chain, next to the owner.
+func WithGenesisAlloc(alloc core.GenesisAlloc) Option {
+	return func(conf *config) {
+		if conf.alloc == nil {
+			conf.alloc = make(core.GenesisAlloc)
+		}
+		for address, account := range alloc {
+			conf.alloc[address] = account
+		}
+	}
+}
+
+// WithLogger sets where the backend reports console.log messages and the errors of
+// transactions mined outside of SendTransaction. NewBacked logs to the test.
+func WithLogger(logger Logger) Option {
+	return func(conf *config) {
+		conf.logger = logger
+	}
+}
+
+// WithSigners lets eth_sendTransaction and eth_accounts of the JSON-RPC API use the
+// accounts, next to the owner.
+func WithSigners(signers ...*bind.TransactOpts) Option {
+	return func(conf *config) {
+		conf.signers = append(conf.signers, signers...)
+	}
+}
+
+// WithDataDir keeps the chain in the directory instead of in memory. A chain found there
+// is resumed, its genesis taking precedence over the allocation of the options.
+func WithDataDir(dir string) Option {
+	return func(conf *config) {
+		conf.dataDir = dir
+	}
+}
@@ -0,0 +1,383 @@
+package bms
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+// rpcModules are the API namespaces served over HTTP and WebSocket.
+var rpcModules = []string{"eth", "net", "web3", "debug", "txpool", "evm", "hardhat", "anvil"}
+
+// ethAPI overrides the methods of the eth namespace that have to go through the backend,
+// so JSON-RPC clients get the behavior of the Go API, automine and fork loading included.
+type ethAPI struct {
+	backend *Backend
+}
+
+// SendRawTransaction sends the signed transaction with Backend.SendTransaction.
+func (api *ethAPI) SendRawTransaction(ctx context.Context, input hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), api.backend.SendTransaction(ctx, tx)
+}
+
+// Accounts returns the accounts eth_sendTransaction signs for, the owner first.
+func (api *ethAPI) Accounts() []common.Address {
+	addresses := make([]common.Address, 0, len(api.backend.signers))
+	for _, signer := range api.backend.signers {
+		addresses = append(addresses, signer.From)
+	}
+	return addresses
+}
+
+// transactionArgs are the arguments of eth_sendTransaction and eth_call the backend
+// uses, fees are always suggested.
+type transactionArgs struct {
+	From  common.Address  `json:"from"`
+	To    *common.Address `json:"to"`
+	Gas   *hexutil.Uint64 `json:"gas"`
+	Value *hexutil.Big    `json:"value"`
+	Data  *hexutil.Bytes  `json:"data"`
+	Input *hexutil.Bytes  `json:"input"`
+}
+
+func (args *transactionArgs) callMsg() ethereum.CallMsg {
+	call := ethereum.CallMsg{From: args.From, To: args.To}
+	if args.Gas != nil {
+		call.Gas = uint64(*args.Gas)
+	}
+	if args.Value != nil {
+		call.Value = args.Value.ToInt()
+	}
+	if args.Input != nil {
+		call.Data = *args.Input
+	} else if args.Data != nil {
+		call.Data = *args.Data
+	}
+	return call
+}
+
+// SendTransaction sends the transaction from one of the signers of the backend (see
+// WithSigners), or from an impersonated account with Backend.SendImpersonated.
+func (api *ethAPI) SendTransaction(ctx context.Context, args transactionArgs) (common.Hash, error) {
+	call := args.callMsg()
+	if api.backend.isImpersonated(args.From) {
+		tx, err := api.backend.SendImpersonated(ctx, call.From, call.To, call.Value, call.Data, call.Gas)
+		if tx == nil {
+			return common.Hash{}, err
+		}
+		return tx.Hash(), err
+	}
+	for _, signer := range api.backend.signers {
+		if signer.From != args.From {
+			continue
+		}
+		opts := *signer
+		opts.Context, opts.Value, opts.GasLimit = ctx, call.Value, call.Gas
+		tx, err := bmsutils.SendDynamicTx(api.backend, &opts, call.To, call.Data)
+		if tx == nil {
+			return common.Hash{}, err
+		}
+		return tx.Hash(), err
+	}
+	return common.Hash{}, errors.Errorf("unknown account %s", args.From.Hex())
+}
+
+// GetBalance runs eth_getBalance, answering from the fork for the accounts the local
+// state does not hold yet.
+func (api *ethAPI) GetBalance(ctx context.Context, address common.Address, block rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	override, err := api.forkAccountState(ctx, &block, address)
+	if err != nil {
+		return nil, err
+	} else if override.remote() {
+		return override.Balance, nil
+	}
+	var balance *hexutil.Big
+	return balance, api.backend.stock.CallContext(ctx, &balance, "eth_getBalance", address, block)
+}
+
+// GetTransactionCount runs eth_getTransactionCount, answering from the fork for the
+// accounts the local state does not hold yet.
+func (api *ethAPI) GetTransactionCount(ctx context.Context, address common.Address, block rpc.BlockNumberOrHash) (*hexutil.Uint64, error) {
+	override, err := api.forkAccountState(ctx, &block, address)
+	if err != nil {
+		return nil, err
+	} else if override.remote() {
+		return override.Nonce, nil
+	}
+	var nonce *hexutil.Uint64
+	return nonce, api.backend.stock.CallContext(ctx, &nonce, "eth_getTransactionCount", address, block)
+}
+
+// GetCode runs eth_getCode, answering from the fork for the accounts the local state
+// does not hold yet.
+func (api *ethAPI) GetCode(ctx context.Context, address common.Address, block rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	override, err := api.forkAccountState(ctx, &block, address)
+	if err != nil {
+		return nil, err
+	} else if override.remote() {
+		return override.Code, nil
+	}
+	var code hexutil.Bytes
+	return code, api.backend.stock.CallContext(ctx, &code, "eth_getCode", address, block)
+}
+
+// GetStorageAt runs eth_getStorageAt, answering from the fork for the storage slots the
+// local state does not hold yet.
+func (api *ethAPI) GetStorageAt(ctx context.Context, address common.Address, key string, block rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	override, err := api.forkAccountState(ctx, &block, address, common.HexToHash(key))
+	if err != nil {
+		return nil, err
+	} else if override != nil {
+		if value, ok := override.StateDiff[common.HexToHash(key)]; ok {
+			return value[:], nil
+		}
+	}
+	var value hexutil.Bytes
+	return value, api.backend.stock.CallContext(ctx, &value, "eth_getStorageAt", address, key, block)
+}
+
+// Call runs eth_call with the state the call touches from the fork added to the state
+// overrides. The arguments are passed on as they are.
+func (api *ethAPI) Call(ctx context.Context, args json.RawMessage, block *rpc.BlockNumberOrHash, overrides, blockOverrides *json.RawMessage) (hexutil.Bytes, error) {
+	overrides, err := api.forkCallState(ctx, args, block, overrides)
+	if err != nil {
+		return nil, err
+	}
+	var output hexutil.Bytes
+	return output, api.backend.stock.CallContext(ctx, &output, "eth_call", args, block, overrides, blockOverrides)
+}
+
+// EstimateGas runs eth_estimateGas with the state the call touches from the fork added to
+// the state overrides. The arguments are passed on as they are.
+func (api *ethAPI) EstimateGas(ctx context.Context, args json.RawMessage, block *rpc.BlockNumberOrHash, overrides *json.RawMessage) (hexutil.Uint64, error) {
+	overrides, err := api.forkCallState(ctx, args, block, overrides)
+	if err != nil {
+		return 0, err
+	}
+	var gas hexutil.Uint64
+	return gas, api.backend.stock.CallContext(ctx, &gas, "eth_estimateGas", args, block, overrides)
+}
+
+// forkAccountState returns the state of the account and of the storage slots from the
+// fork for the reads of the latest state, see Backend.forkAccountState.
+func (api *ethAPI) forkAccountState(ctx context.Context, block *rpc.BlockNumberOrHash, address common.Address, keys ...common.Hash) (*forkOverride, error) {
+	if api.backend.fork == nil || !isLatest(block) {
+		return nil, nil
+	}
+	return api.backend.forkAccountState(ctx, address, keys...)
+}
+
+// forkCallState adds the state the call on the latest state touches from the fork to
+// the state overrides of the client. The overrides of the client take precedence, the
+// slots of their stateDiff are merged with those from the fork.
+func (api *ethAPI) forkCallState(ctx context.Context, args json.RawMessage, block *rpc.BlockNumberOrHash, overrides *json.RawMessage) (*json.RawMessage, error) {
+	if api.backend.fork == nil || !isLatest(block) {
+		return overrides, nil
+	}
+	var call transactionArgs
+	if err := json.Unmarshal(args, &call); err != nil {
+		return nil, err
+	}
+	state, err := api.backend.forkCallState(ctx, call.callMsg())
+	if err != nil || len(state) == 0 {
+		return overrides, err
+	}
+
+	merged := make(map[common.Address]map[string]json.RawMessage, len(state))
+	for address, override := range state {
+		bytes, err := json.Marshal(override)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(bytes, &fields); err != nil {
+			return nil, err
+		}
+		merged[address] = fields
+	}
+	if overrides != nil {
+		var client map[common.Address]map[string]json.RawMessage
+		if err := json.Unmarshal(*overrides, &client); err != nil {
+			return nil, err
+		}
+		for address, fields := range client {
+			forked := merged[address]
+			if forked == nil {
+				merged[address] = fields
+				continue
+			}
+			if _, ok := fields["state"]; ok {
+				delete(forked, "stateDiff")
+			} else if diff, ok := fields["stateDiff"]; ok {
+				// unmarshaling into the slots from the fork overwrites them
+				stateDiff := state[address].StateDiff
+				if err := json.Unmarshal(diff, &stateDiff); err != nil {
+					return nil, err
+				}
+				if fields["stateDiff"], err = json.Marshal(stateDiff); err != nil {
+					return nil, err
+				}
+			}
+			for name, value := range fields {
+				forked[name] = value
+			}
+		}
+	}
+	bytes, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	raw := json.RawMessage(bytes)
+	return &raw, nil
+}
+
+// isLatest reports whether the block is the latest or the pending one, the default when
+// it is omitted.
+func isLatest(block *rpc.BlockNumberOrHash) bool {
+	if block == nil {
+		return true
+	}
+	number, ok := block.Number()
+	return ok && (number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber)
+}
+
+// quantity is a number sent either as a JSON number or as a hex string, as the clients
+// of the evm and hardhat methods do.
+type quantity uint64
+
+func (q *quantity) UnmarshalJSON(input []byte) error {
+	if len(input) > 0 && input[0] == '"' {
+		return (*hexutil.Uint64)(q).UnmarshalJSON(input)
+	}
+	return json.Unmarshal(input, (*uint64)(q))
+}
+
+// evmAPI serves the evm namespace of hardhat and ganache.
+type evmAPI struct {
+	backend *Backend
+}
+
+// Mine seals a block, at the timestamp if one is given.
+func (api *evmAPI) Mine(timestamp *quantity) (string, error) {
+	if timestamp != nil {
+		if err := api.backend.SetNextBlockTimestamp(uint64(*timestamp)); err != nil {
+			return "", err
+		}
+	}
+	if _, err := api.backend.tryCommit(); err != nil {
+		return "", err
+	}
+	return "0x0", nil
+}
+
+// IncreaseTime moves the clock forward and returns the total time added, in seconds.
+func (api *evmAPI) IncreaseTime(seconds quantity) int64 {
+	return int64(api.backend.IncreaseTime(time.Duration(seconds)*time.Second) / time.Second)
+}
+
+// SetNextBlockTimestamp sets the timestamp of the next block.
+func (api *evmAPI) SetNextBlockTimestamp(timestamp quantity) error {
+	return api.backend.SetNextBlockTimestamp(uint64(timestamp))
+}
+
+// Snapshot saves the state of the chain and returns its id.
+func (api *evmAPI) Snapshot() hexutil.Uint64 {
+	return hexutil.Uint64(api.backend.Snapshot())
+}
+
+// Revert brings the chain back to the snapshot, false if the snapshot is unknown.
+func (api *evmAPI) Revert(id quantity) (bool, error) {
+	if err := api.backend.Revert(uint64(id)); errors.Is(err, ErrUnknownSnapshot) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetAutomine switches between AutoMine and ManualMine.
+func (api *evmAPI) SetAutomine(enable bool) {
+	api.backend.SetAutomine(enable)
+}
+
+// SetIntervalMining seals a block every interval, in milliseconds, 0 disabling it.
+func (api *evmAPI) SetIntervalMining(interval quantity) {
+	api.backend.SetIntervalMining(time.Duration(interval) * time.Millisecond)
+}
+
+// hardhatAPI serves the hardhat namespace, and the same methods of anvil.
+type hardhatAPI struct {
+	backend *Backend
+}
+
+// ImpersonateAccount lets eth_sendTransaction send transactions from the account.
+func (api *hardhatAPI) ImpersonateAccount(address common.Address) bool {
+	api.backend.Impersonate(address)
+	return true
+}
+
+// StopImpersonatingAccount undoes ImpersonateAccount.
+func (api *hardhatAPI) StopImpersonatingAccount(address common.Address) bool {
+	api.backend.StopImpersonating(address)
+	return true
+}
+
+// SetBalance sets the balance of the account. Unlike hardhat, it mines an empty block.
+func (api *hardhatAPI) SetBalance(address common.Address, balance hexutil.Big) (bool, error) {
+	return true, api.backend.SetBalance(address, balance.ToInt())
+}
+
+// SetNonce sets the nonce of the account. Unlike hardhat, it mines an empty block.
+func (api *hardhatAPI) SetNonce(address common.Address, nonce quantity) (bool, error) {
+	return true, api.backend.SetNonce(address, uint64(nonce))
+}
+
+// SetCode sets the code of the account. Unlike hardhat, it mines an empty block.
+func (api *hardhatAPI) SetCode(address common.Address, code hexutil.Bytes) (bool, error) {
+	return true, api.backend.SetCode(address, code)
+}
+
+// SetStorageAt sets the slot at position to the 32 bytes of value. Unlike hardhat, it
+// mines an empty block.
+func (api *hardhatAPI) SetStorageAt(address common.Address, position hexutil.Big, value hexutil.Bytes) (bool, error) {
+	if len(value) != common.HashLength {
+		return false, errors.Errorf("storage value must be %d bytes, got %d", common.HashLength, len(value))
+	}
+	key := common.BigToHash(position.ToInt())
+	return true, api.backend.SetStorageAt(address, key, common.BytesToHash(value))
+}
+
+// Mine seals blocks, 1 by default, interval seconds apart, 1 by default.
+func (api *hardhatAPI) Mine(blocks, interval *quantity) (bool, error) {
+	count, seconds := uint64(1), uint64(1)
+	if blocks != nil {
+		count = uint64(*blocks)
+	}
+	if interval != nil {
+		seconds = uint64(*interval)
+	}
+	for i := uint64(0); i < count; i++ {
+		if i != 0 && seconds > 1 {
+			head := api.backend.eth.BlockChain().CurrentBlock()
+			if err := api.backend.SetNextBlockTimestamp(head.Time + seconds); err != nil {
+				return false, err
+			}
+		}
+		if _, err := api.backend.tryCommit(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
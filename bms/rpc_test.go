@@ -0,0 +1,153 @@
+package bms_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHardhatRPC(t *testing.T) {
+	backend := bms.NewBacked(t, bms.WithHTTP("127.0.0.1", 0))
+	ctx := context.Background()
+
+	client, err := rpc.DialContext(ctx, backend.HTTPEndpoint())
+	require.NoError(t, err)
+	defer client.Close()
+	ec := ethclient.NewClient(client)
+
+	var ok bool
+	whale := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	target := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+
+	// state
+	before, err := ec.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, client.Call(&ok, "hardhat_setBalance", whale, (*hexutil.Big)(bmsutils.ToWei(10))))
+	balance, err := ec.BalanceAt(ctx, whale, nil)
+	require.NoError(t, err)
+	require.Equal(t, bmsutils.ToWei(10), balance)
+
+	// target stores msg.sender at slot 0
+	code := program(op(vm.CALLER), push([]byte{0}), op(vm.SSTORE), op(vm.STOP))
+	require.NoError(t, client.Call(&ok, "anvil_setCode", target, hexutil.Bytes(code)))
+	stored, err := ec.CodeAt(ctx, target, nil)
+	require.NoError(t, err)
+	require.Equal(t, code, stored)
+
+	value := common.HexToHash("0x2a")
+	require.NoError(t, client.Call(&ok, "hardhat_setStorageAt", target, "0x1", value))
+	slot, err := ec.StorageAt(ctx, target, common.HexToHash("0x1"), nil)
+	require.NoError(t, err)
+	require.Equal(t, value.Bytes(), slot)
+
+	// every change of the state mines an empty block, keeping the timestamp
+	after, err := ec.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, before.Number.Uint64()+3, after.Number.Uint64())
+	require.Equal(t, before.Time, after.Time)
+	require.Equal(t, types.EmptyTxsHash, after.TxHash)
+
+	// snapshot and revert
+	var id hexutil.Uint64
+	require.NoError(t, client.Call(&id, "evm_snapshot"))
+	require.NoError(t, client.Call(&ok, "hardhat_setNonce", whale, 5))
+	nonce, err := ec.NonceAt(ctx, whale, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), nonce)
+
+	require.NoError(t, client.Call(&ok, "evm_revert", id))
+	require.True(t, ok)
+	nonce, err = ec.NonceAt(ctx, whale, nil)
+	require.NoError(t, err)
+	require.Zero(t, nonce)
+	require.NoError(t, client.Call(&ok, "evm_revert", id))
+	require.False(t, ok)
+
+	// time
+	head, err := ec.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, client.Call(nil, "evm_setNextBlockTimestamp", head.Time+1000))
+	require.NoError(t, client.Call(nil, "evm_mine"))
+	head, err = ec.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	mined := head.Time
+
+	var offset, total int64
+	require.NoError(t, client.Call(&offset, "evm_increaseTime", 0))
+	require.NoError(t, client.Call(&total, "evm_increaseTime", "0xe10"))
+	require.Equal(t, offset+3600, total)
+	require.NoError(t, client.Call(nil, "evm_mine"))
+	head, err = ec.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, head.Time, mined+3600)
+
+	// impersonation
+	var accounts []common.Address
+	require.NoError(t, client.Call(&accounts, "eth_accounts"))
+	require.Equal(t, []common.Address{backend.Owner.From}, accounts)
+
+	args := map[string]interface{}{"from": whale, "to": target}
+	var hash common.Hash
+	require.Error(t, client.Call(&hash, "eth_sendTransaction", args))
+	require.NoError(t, client.Call(&ok, "hardhat_impersonateAccount", whale))
+	require.NoError(t, client.Call(&hash, "eth_sendTransaction", args))
+
+	receipt, err := ec.TransactionReceipt(ctx, hash)
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	slot, err = ec.StorageAt(ctx, target, common.Hash{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, common.BytesToHash(whale.Bytes()).Bytes(), slot)
+
+	eoa := bms.GetTEoa(t)
+	args = map[string]interface{}{"from": whale, "to": eoa.From, "value": (*hexutil.Big)(bmsutils.ToWei(1))}
+	require.NoError(t, client.Call(&hash, "eth_sendTransaction", args))
+	balance, err = ec.BalanceAt(ctx, eoa.From, nil)
+	require.NoError(t, err)
+	require.Equal(t, bmsutils.ToWei(1), balance)
+
+	// the account is restored once its transactions are mined
+	stored, err = ec.CodeAt(ctx, whale, nil)
+	require.NoError(t, err)
+	require.Empty(t, stored)
+	nonce, err = ec.NonceAt(ctx, whale, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), nonce)
+	balance, err = ec.BalanceAt(ctx, whale, nil)
+	require.NoError(t, err)
+	require.Equal(t, bmsutils.ToWei(9), balance)
+
+	// contracts created on behalf of the account get its address and nonce
+	args = map[string]interface{}{"from": whale, "data": hexutil.Bytes(deployCode(code))}
+	require.NoError(t, client.Call(&hash, "eth_sendTransaction", args))
+	stored, err = ec.CodeAt(ctx, crypto.CreateAddress(whale, 2), nil)
+	require.NoError(t, err)
+	require.Equal(t, code, stored)
+
+	require.NoError(t, client.Call(&ok, "hardhat_stopImpersonatingAccount", whale))
+	require.Error(t, client.Call(&hash, "eth_sendTransaction", args))
+}
+
+func TestMineError(t *testing.T) {
+	backend := bms.NewBacked(t, bms.WithHTTP("127.0.0.1", 0))
+	client, err := rpc.DialContext(context.Background(), backend.HTTPEndpoint())
+	require.NoError(t, err)
+	defer client.Close()
+
+	// no block can follow the last timestamp
+	require.NoError(t, client.Call(nil, "evm_mine", "0xffffffffffffffff"))
+	require.ErrorContains(t, client.Call(nil, "evm_mine"), "invalid timestamp")
+	var ok bool
+	require.ErrorContains(t, client.Call(&ok, "hardhat_mine"), "invalid timestamp")
+	require.False(t, ok)
+}
@@ -0,0 +1,92 @@
+package bms_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+// erc1271Code answers isValidSignature with the magic value, or reverts if it is nil.
+func erc1271Code(magic []byte) []byte {
+	if magic == nil {
+		return program(push([]byte{0}), push([]byte{0}), op(vm.REVERT))
+	}
+	return program(
+		push(magic), push([]byte{0xe0}), op(vm.SHL), push([]byte{0}), op(vm.MSTORE),
+		push([]byte{0x20}), push([]byte{0}), op(vm.RETURN),
+	)
+}
+
+func TestSignMessage(t *testing.T) {
+	eoa, other := bms.GetTEoa(t), bms.GetTEoa(t)
+	message := []byte("hello bms")
+
+	// personal_sign
+	signature, err := bmsutils.SignMessage(eoa, message)
+	require.NoError(t, err)
+	signer, err := bmsutils.RecoverMessage(message, signature.Bytes)
+	require.NoError(t, err)
+	require.Equal(t, eoa.From, signer)
+	require.Equal(t, common.BytesToHash(crypto.Keccak256([]byte("\x19Ethereum Signed Message:\n9hello bms"))), bmsutils.MessageHash(message))
+
+	// 키로 직접 서명한 결과와 같다.
+	key := bms.GetTKey(t, eoa)
+	require.Equal(t, eoa.From, crypto.PubkeyToAddress(key.PublicKey))
+	same, err := bmsutils.SignMessageWithKey(key, message)
+	require.NoError(t, err)
+	require.Equal(t, signature, same)
+
+	// v 가 0, 1 인 서명도 복원한다.
+	hash := crypto.Keccak256Hash([]byte("raw"))
+	signature, err = bmsutils.SignHash(other, hash)
+	require.NoError(t, err)
+	raw := common.CopyBytes(signature.Bytes)
+	raw[64] -= 27
+	signer, err = bmsutils.RecoverHash(hash, raw)
+	require.NoError(t, err)
+	require.Equal(t, other.From, signer)
+	split, err := bmsutils.SplitSignature(raw)
+	require.NoError(t, err)
+	require.Equal(t, signature, split)
+	_, err = bmsutils.SplitSignature(raw[:64])
+	require.Error(t, err)
+}
+
+func TestIsValidSignature(t *testing.T) {
+	backend := bms.NewBacked(t)
+	ctx := context.Background()
+	eoa, other := bms.GetTEoa(t), bms.GetTEoa(t)
+	hash := crypto.Keccak256Hash([]byte("order"))
+	signature, err := bmsutils.SignHash(eoa, hash)
+	require.NoError(t, err)
+
+	// EOA 는 서명자를 복원한다.
+	valid, err := bmsutils.IsValidSignature(ctx, backend, eoa.From, hash, signature.Bytes)
+	require.NoError(t, err)
+	require.True(t, valid)
+	valid, err = bmsutils.IsValidSignature(ctx, backend, other.From, hash, signature.Bytes)
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	// 컨트랙트 계정은 ERC-1271 로 확인한다.
+	wallet := common.HexToAddress("0x0000000000000000000000000000000000001271")
+	for _, c := range []struct {
+		magic []byte
+		valid bool
+	}{
+		{crypto.Keccak256([]byte("isValidSignature(bytes32,bytes)"))[:4], true},
+		{[]byte{0xff, 0xff, 0xff, 0xff}, false},
+		{nil, false},
+	} {
+		require.NoError(t, backend.SetCode(wallet, erc1271Code(c.magic)))
+		valid, err := bmsutils.IsValidSignature(ctx, backend, wallet, hash, signature.Bytes)
+		require.NoError(t, err)
+		require.Equal(t, c.valid, valid)
+	}
+}
@@ -2,9 +2,12 @@ package bms
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"math/big"
+	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
 	"github.com/ethereum/go-ethereum"
@@ -12,67 +15,521 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/catalyst"
+	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
 )
 
 var ChainID *big.Int = params.AllDevChainProtocolChanges.ChainID
 
 type Backend struct {
-	simulated.Backend
 	simulated.Client
 	Owner *bind.TransactOpts
+
+	node    *node.Node
+	eth     *eth.Ethereum
+	beacon  *catalyst.SimulatedBeacon
+	rpc     *rpc.Client
+	stock   *rpc.Client
+	fork    *fork
+	logger  Logger
+	gasTip  *big.Int
+	resumed bool
+
+	// signers are the accounts eth_sendTransaction signs for, the owner first
+	signers []*bind.TransactOpts
+
+	// eoaCount is the number of accounts Eoas allocated
+	eoaLock  sync.Mutex
+	eoaCount uint32
+
+	eip1559     bool
+	tracing     bool
+	console     bool
+	callConsole bool
+
+	gasReporter *GasReporter
+	gasRecords  []gasRecord
+	gasLock     sync.Mutex
+
+	miningLock   sync.Mutex
+	miningMode   MiningMode
+	intervalStop chan struct{}
+	intervalDone chan struct{}
+
+	// commitLock guards the head of the chain and the fields below.
+	commitLock     sync.Mutex
+	nextTimestamp  uint64
+	timeOffset     int64
+	snapshots      []snapshot
+	impersonated   map[common.Address]bool
+	impersonations map[common.Address]*impersonation
 }
 
-func NewBacked(t *testing.T) *Backend {
-	minerGasPrice := new(big.Int).SetBytes(ethconfig.Defaults.Miner.GasPrice.Bytes())
-	ethconfig.Defaults.Miner.GasPrice.SetBytes([]byte{})
-	defer ethconfig.Defaults.Miner.GasPrice.SetBytes(minerGasPrice.Bytes())
+// Logger receives the messages the backend reports outside of a call, e.g. *testing.T.
+type Logger interface {
+	Log(args ...interface{})
+}
 
-	owner := GetTOwner(t)
-	ethconfig.Defaults.Miner.GasPrice = common.Big0
-	backend := simulated.NewBackend(
-		core.GenesisAlloc{
-			owner.From: core.GenesisAccount{Balance: bmsutils.ToWei(common.Big256)},
-		},
-		simulated.WithBlockGasLimit(params.MaxGasLimit),
+func NewBacked(t *testing.T, options ...Option) *Backend {
+	backend, err := NewBackend(append([]Option{WithLogger(t)}, options...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { backend.Close() })
+	return backend
+}
+
+// NewBackend creates the backend of NewBacked outside of tests, e.g. to serve it over
+// JSON-RPC. The owner is the first account of the wallet. Close it when done.
+func NewBackend(options ...Option) (*Backend, error) {
+	conf := newConfig(options...)
+
+	owner, err := GetEoaAt(ChainID, 0)
+	if err != nil {
+		return nil, err
+	}
+	alloc := core.GenesisAlloc{
+		owner.From: core.GenesisAccount{Balance: bmsutils.ToWei(common.Big256)},
+	}
+	for address, account := range conf.alloc {
+		alloc[address] = account
+	}
+
+	var remote *fork
+	if conf.forkURL != "" && conf.dataDir != "" {
+		return nil, errors.New("a forked chain can't be kept in a data directory")
+	}
+	if conf.forkURL != "" {
+		if remote, err = newFork(context.Background(), conf.forkURL, conf.forkBlock, conf.forkCache); err != nil {
+			return nil, err
+		}
+		for address := range alloc {
+			remote.setLocal(address)
+		}
+	}
+	backend, err := newBackend(
+		alloc,
 		func(nodeConf *node.Config, ethConf *ethconfig.Config) {
+			ethConf.Genesis.GasLimit = params.MaxGasLimit
+			ethConf.Miner.GasCeil = params.MaxGasLimit
 			ethConf.Genesis.Coinbase = owner.From
-			ethConf.Genesis.BaseFee = common.Big0
-			ethConf.TxPool.PriceLimit = 0
+			if conf.eip1559 {
+				ethConf.Genesis.BaseFee = big.NewInt(params.InitialBaseFee)
+				ethConf.Miner.GasPrice = big.NewInt(params.GWei)
+			} else {
+				ethConf.Genesis.BaseFee = common.Big0
+				ethConf.Miner.GasPrice = common.Big0
+				ethConf.TxPool.PriceLimit = 0
+			}
+			if remote != nil {
+				ethConf.Genesis.Timestamp = remote.header.Time
+			}
+			if conf.dataDir != "" {
+				nodeConf.Name = "bms"
+				nodeConf.DataDir = conf.dataDir
+			}
+			if conf.http {
+				nodeConf.HTTPHost = conf.httpHost
+				nodeConf.HTTPPort = conf.httpPort
+				nodeConf.HTTPModules = rpcModules
+				nodeConf.HTTPCors = []string{"*"}
+				nodeConf.HTTPVirtualHosts = []string{"*"}
+			}
+			if conf.ws {
+				nodeConf.WSHost = conf.wsHost
+				nodeConf.WSPort = conf.wsPort
+				nodeConf.WSModules = rpcModules
+				nodeConf.WSOrigins = []string{"*"}
+			}
 		},
 	)
+	if err != nil {
+		if remote != nil {
+			remote.remote.Close()
+		}
+		return nil, err
+	}
 
-	return &Backend{
-		Backend: *backend,
-		Client:  backend.Client(),
-		Owner:   owner,
+	backend.Owner = owner
+	backend.signers = []*bind.TransactOpts{owner}
+	for _, signer := range conf.signers {
+		if signer.From != owner.From {
+			backend.signers = append(backend.signers, signer)
+		}
 	}
+	backend.fork = remote
+	backend.logger = conf.logger
+	backend.eip1559 = conf.eip1559
+	backend.tracing = conf.tracing
+	backend.console = conf.console
+	backend.callConsole = conf.callConsole
+	backend.gasReporter = conf.gasReporter
+	switch conf.miningMode {
+	case ManualMine:
+		backend.SetAutomine(false)
+	case IntervalMine:
+		backend.SetIntervalMining(conf.miningInterval)
+	}
+	return backend, nil
+}
+
+// newBackend assembles an in-memory node serving the eth, filter and debug tracing
+// APIs, the same way simulated.NewBackend does.
+func newBackend(alloc core.GenesisAlloc, options ...func(nodeConf *node.Config, ethConf *ethconfig.Config)) (_ *Backend, err error) {
+	nodeConf := node.DefaultConfig
+	nodeConf.DataDir = ""
+	nodeConf.P2P = p2p.Config{NoDiscovery: true}
+
+	ethConf := ethconfig.Defaults
+	ethConf.Genesis = &core.Genesis{
+		Config:   params.AllDevChainProtocolChanges,
+		GasLimit: ethconfig.Defaults.Miner.GasCeil,
+		Alloc:    alloc,
+	}
+	ethConf.SyncMode = downloader.FullSync
+	// keep the state of every block, so the chain can go back to any of them
+	ethConf.NoPruning = true
+	// keep the addresses and slots behind the hashes of the state, for DumpState
+	ethConf.Preimages = true
+	ethConf.TxPool.NoLocals = true
+
+	for _, option := range options {
+		option(&nodeConf, &ethConf)
+	}
+
+	stack, err := node.New(&nodeConf)
+	if err != nil {
+		return nil, err
+	}
+	// release the data directory and the listeners when anything below fails
+	defer func() {
+		if err != nil {
+			stack.Close()
+		}
+	}()
+	backend := new(Backend)
+	if nodeConf.DataDir != "" {
+		// resume the chain of the data directory with the genesis it was created with
+		if _, err := os.Stat(stack.ResolvePath("chaindata")); err == nil {
+			ethConf.Genesis = nil
+			backend.resumed = true
+		}
+	}
+	ethereum, err := newEthereum(stack, &ethConf)
+	if err != nil {
+		return nil, err
+	}
+	// the eth namespace of geth, which the methods of ethAPI call after the fork is loaded
+	stock := rpc.NewServer()
+	for _, api := range ethereum.APIs() {
+		if api.Namespace != "eth" {
+			continue
+		}
+		if err = stock.RegisterName(api.Namespace, api.Service); err != nil {
+			return nil, err
+		}
+	}
+	filterSystem := filters.NewFilterSystem(ethereum.APIBackend, filters.Config{})
+	stack.RegisterAPIs([]rpc.API{{
+		Namespace: "eth",
+		Service:   filters.NewFilterAPI(filterSystem, false),
+	}})
+	stack.RegisterAPIs(tracers.APIs(ethereum.APIBackend))
+	stack.RegisterAPIs([]rpc.API{{
+		Namespace: "eth",
+		Service:   &ethAPI{backend},
+	}, {
+		Namespace: "evm",
+		Service:   &evmAPI{backend},
+	}, {
+		Namespace: "hardhat",
+		Service:   &hardhatAPI{backend},
+	}, {
+		Namespace: "anvil",
+		Service:   &hardhatAPI{backend},
+	}})
+	if err = stack.Start(); err != nil {
+		return nil, err
+	}
+
+	beacon, err := catalyst.NewSimulatedBeacon(0, ethereum)
+	if err != nil {
+		return nil, err
+	}
+	if ethereum.BlockChain().CurrentBlock().Number.Sign() == 0 {
+		if err = beacon.Fork(ethereum.BlockChain().GetCanonicalHash(0)); err != nil {
+			return nil, err
+		}
+	}
+
+	client := stack.Attach()
+	backend.Client = ethclient.NewClient(client)
+	backend.node = stack
+	backend.eth = ethereum
+	backend.beacon = beacon
+	backend.rpc = client
+	backend.stock = rpc.DialInProc(stock)
+	backend.gasTip = ethConf.Miner.GasPrice
+	return backend, nil
+}
+
+// eth.New replaces a zero miner tip with the default one, so the default is lowered
+// while the service is created to let zero-fee transactions be mined.
+var minerDefaultsLock sync.Mutex
+
+func newEthereum(stack *node.Node, ethConf *ethconfig.Config) (*eth.Ethereum, error) {
+	minerDefaultsLock.Lock()
+	defer minerDefaultsLock.Unlock()
+
+	defaultGasPrice := ethconfig.Defaults.Miner.GasPrice
+	if ethConf.Miner.GasPrice != nil && ethConf.Miner.GasPrice.Sign() == 0 {
+		ethconfig.Defaults.Miner.GasPrice = ethConf.Miner.GasPrice
+	}
+	defer func() { ethconfig.Defaults.Miner.GasPrice = defaultGasPrice }()
+
+	return eth.New(stack, ethConf)
 }
 
 func (ec *Backend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	if ec.eip1559 {
+		return ec.Client.SuggestGasPrice(ctx)
+	}
 	return common.Big0, nil
 }
 
 func (ec *Backend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	if ec.eip1559 {
+		return ec.Client.SuggestGasTipCap(ctx)
+	}
 	return common.Big0, nil
 }
 
-func (ec *Backend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
-	receipt, err := ec.Client.TransactionReceipt(ctx, txHash)
-	if err != nil && (errors.Is(err, ethereum.NotFound) || err.Error() == "transaction indexing is in progress") {
-		ec.Commit()
+func (ec *Backend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	output, err := ec.Client.CallContract(ctx, call, blockNumber)
+	if ec.callConsole {
+		if trace, traceErr := ec.traceCall(ctx, call, toBlockNumArg(blockNumber)); traceErr == nil {
+			ec.printConsoleLogs(trace)
+		}
 	}
-	return receipt, err
+	return output, err
 }
 
 func (ec *Backend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
 	gas, err := ec.Client.EstimateGas(ctx, call)
+	if (err != nil && ec.tracing) || ec.callConsole {
+		if trace, traceErr := ec.TraceCall(ctx, call); traceErr == nil {
+			if ec.callConsole {
+				ec.printConsoleLogs(trace)
+			}
+			if err != nil && ec.tracing && trace.Failed() {
+				return gas, &bmsutils.TraceError{Err: bmsutils.ToRevert(err), Trace: trace}
+			}
+		}
+	}
 	return gas, bmsutils.ToRevert(err)
 }
 
 func (ec *Backend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
-	return bmsutils.ToRevert(ec.Client.SendTransaction(ctx, tx))
+	if err := ec.forkLoadTx(ctx, tx); err != nil {
+		return err
+	}
+	if err := ec.eth.APIBackend.SendTx(ctx, tx); err != nil {
+		return bmsutils.ToRevert(err)
+	}
+	if ec.MiningMode() != AutoMine {
+		return nil
+	}
+
+	hash, err := ec.commit()
+	if err != nil {
+		return err
+	}
+	failures := ec.processBlock(hash)
+	err = failures[tx.Hash()]
+	delete(failures, tx.Hash())
+	ec.report(failures)
+	return err
+}
+
+// Commit seals a block with the pending transactions and moves the chain forward. A
+// block that can't be sealed is logged as the simulated beacon does, and the hash of the
+// latest block is returned.
+func (ec *Backend) Commit() common.Hash {
+	hash, err := ec.tryCommit()
+	if err != nil {
+		log.Warn("Error performing sealing work", "err", err)
+	}
+	return hash
+}
+
+// tryCommit is Commit, returning the error of sealing instead of logging it.
+func (ec *Backend) tryCommit() (common.Hash, error) {
+	hash, err := ec.commit()
+	if err != nil {
+		return hash, err
+	}
+	ec.report(ec.processBlock(hash))
+	return hash, nil
+}
+
+// Rollback removes all pending transactions, reverting to the last committed state.
+// Accounts impersonated for the removed transactions are restored in a new block.
+func (ec *Backend) Rollback() {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	ec.flushTxPool()
+	if err := ec.dropImpersonations(); err != nil {
+		log.Warn("Failed to restore impersonated accounts", "err", err)
+	}
+}
+
+// Fork creates a side-chain that can be used to simulate reorgs.
+func (ec *Backend) Fork(parentHash common.Hash) error {
+	if ec.fork != nil {
+		ec.fork.lock.Lock()
+		defer ec.fork.lock.Unlock()
+	}
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+	if err := ec.beacon.Fork(parentHash); err != nil {
+		return err
+	}
+	if ec.fork != nil {
+		ec.fork.rewind(ec.eth.BlockChain().CurrentBlock().Number.Uint64())
+	}
+	return nil
+}
+
+// Resumed reports whether the chain was resumed from the data directory of WithDataDir.
+func (ec *Backend) Resumed() bool {
+	return ec.resumed
+}
+
+// HTTPEndpoint returns the URL of the JSON-RPC server started by WithHTTP.
+func (ec *Backend) HTTPEndpoint() string {
+	return ec.node.HTTPEndpoint()
+}
+
+// WSEndpoint returns the URL of the JSON-RPC server started by WithWS.
+func (ec *Backend) WSEndpoint() string {
+	return ec.node.WSEndpoint()
+}
+
+// AdjustTime seals an empty block whose timestamp is adjusted from its parent.
+func (ec *Backend) AdjustTime(adjustment time.Duration) error {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	if len(ec.eth.TxPool().Pending(false)) != 0 {
+		return errors.New("could not adjust time on non-empty block")
+	}
+	parent := ec.eth.BlockChain().CurrentBlock()
+	_, err := ec.seal(parent.Time + uint64(adjustment/time.Second))
+	return err
+}
+
+// Close stops mining and shuts down the node. The backend can't be used afterwards.
+func (ec *Backend) Close() error {
+	ec.miningLock.Lock()
+	ec.stopInterval()
+	ec.miningLock.Unlock()
+
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+	if ec.node == nil {
+		return nil
+	}
+	ec.reportGas()
+	ec.rpc.Close()
+	ec.stock.Close()
+	if ec.fork != nil {
+		ec.fork.remote.Close()
+	}
+	ec.beacon.Stop()
+	err := ec.node.Close()
+	ec.node = nil
+	return err
+}
+
+// commit seals a block and restores the impersonated accounts whose transactions it
+// mined. It returns the hash of the latest block with the error of sealing.
+func (ec *Backend) commit() (common.Hash, error) {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	hash, err := ec.seal(ec.timestamp())
+	if err != nil {
+		return ec.eth.BlockChain().CurrentBlock().Hash(), err
+	}
+	if err := ec.restoreImpersonated(hash); err != nil {
+		log.Warn("Failed to restore impersonated accounts", "err", err)
+	}
+	return hash, nil
+}
+
+// processBlock records the gas used by the transactions of a freshly sealed block, and
+// traces them when tracing or console.log is enabled. It prints their console.log
+// messages and returns the decoded errors of the failed ones.
+func (ec *Backend) processBlock(blockHash common.Hash) map[common.Hash]error {
+	if !ec.tracing && !ec.console && ec.gasReporter == nil {
+		return nil
+	}
+
+	block := ec.eth.BlockChain().GetBlockByHash(blockHash)
+	receipts := ec.eth.BlockChain().GetReceiptsByHash(blockHash)
+	if block == nil || len(receipts) != len(block.Transactions()) {
+		return nil
+	}
+	ec.recordGas(block, receipts)
+	if !ec.tracing && !ec.console {
+		return nil
+	}
+
+	failures := make(map[common.Hash]error)
+	var frames []*bmsutils.CallFrame
+	for i, receipt := range receipts {
+		failed := receipt.Status != types.ReceiptStatusSuccessful
+		if !ec.console && !failed {
+			continue
+		}
+		if frames == nil {
+			var err error
+			if frames, err = ec.traceBlock(context.Background(), blockHash); err != nil {
+				if failed {
+					failures[receipt.TxHash] = fmt.Errorf("transaction %s reverted (trace: %v)", receipt.TxHash.Hex(), err)
+				}
+				continue
+			}
+		}
+		if ec.console {
+			ec.printConsoleLogs(frames[i])
+		}
+		if ec.tracing && failed {
+			failures[receipt.TxHash] = toTraceError(frames[i])
+		}
+	}
+	return failures
+}
+
+// report logs errors of the transactions mined outside of SendTransaction.
+func (ec *Backend) report(failures map[common.Hash]error) {
+	if ec.logger == nil {
+		return
+	}
+	for hash, err := range failures {
+		ec.logger.Log(hash.Hex(), err)
+	}
 }
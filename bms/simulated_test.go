@@ -2,12 +2,18 @@ package bms_test
 
 import (
 	"context"
+	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/bang9ming9/go-hardhat/bms"
 	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/stretchr/testify/require"
 )
 
@@ -47,3 +53,187 @@ func TestNewBackend(t *testing.T) {
 	require.NoError(t, err)
 	t.Log(eoa.From, "balance", balance)
 }
+
+func TestNewBackendEIP1559(t *testing.T) {
+	backend := bms.NewBacked(t, bms.WithEIP1559())
+	ctx := context.Background()
+
+	tip, err := backend.SuggestGasTipCap(ctx)
+	require.NoError(t, err)
+	require.Positive(t, tip.Sign())
+
+	head, err := backend.HeaderByNumber(ctx, nil)
+	require.NoError(t, err)
+	require.Positive(t, head.BaseFee.Sign())
+
+	before, err := backend.BalanceAt(ctx, backend.Owner.From, nil)
+	require.NoError(t, err)
+
+	eoa := bms.GetTEoa(t)
+	txpool := bmsutils.NewTxPool(backend)
+
+	backend.Owner.Value = bmsutils.ToWei(1)
+	require.NoError(t, txpool.Exec(bmsutils.SendDynamicTx(backend, backend.Owner, &eoa.From, []byte{})))
+	backend.Owner.Value = common.Big0
+
+	receipts, err := txpool.WaitMined(ctx)
+	require.NoError(t, err)
+	require.Len(t, receipts, 1)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipts[0].Status)
+
+	cost := bmsutils.GasCost(receipts...)
+	require.Positive(t, cost.Sign())
+
+	after, err := backend.BalanceAt(ctx, backend.Owner.From, nil)
+	require.NoError(t, err)
+	require.Equal(t, new(big.Int).Sub(before, new(big.Int).Add(bmsutils.ToWei(1), cost)), after)
+}
+
+func TestMiningMode(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("automine", func(t *testing.T) {
+		backend := bms.NewBacked(t)
+		require.Equal(t, bms.AutoMine, backend.MiningMode())
+
+		eoa := bms.GetTEoa(t)
+		tx1, err := bmsutils.SendDynamicTx(backend, backend.Owner, &eoa.From, []byte{})
+		require.NoError(t, err)
+		tx2, err := bmsutils.SendDynamicTx(backend, backend.Owner, &eoa.From, []byte{})
+		require.NoError(t, err)
+
+		receipt1, err := backend.TransactionReceipt(ctx, tx1.Hash())
+		require.NoError(t, err)
+		receipt2, err := backend.TransactionReceipt(ctx, tx2.Hash())
+		require.NoError(t, err)
+		require.Equal(t, receipt1.BlockNumber.Uint64()+1, receipt2.BlockNumber.Uint64())
+	})
+
+	t.Run("automine back to back", func(t *testing.T) {
+		backend := bms.NewBacked(t)
+		eoa := bms.GetTEoa(t)
+
+		for i := 0; i < 20; i++ {
+			tx, err := bmsutils.SendDynamicTx(backend, backend.Owner, &eoa.From, []byte{})
+			require.NoError(t, err)
+			receipt, err := backend.TransactionReceipt(ctx, tx.Hash())
+			require.NoError(t, err)
+			block, err := backend.BlockByHash(ctx, receipt.BlockHash)
+			require.NoError(t, err)
+			require.Len(t, block.Transactions(), 1)
+			require.Equal(t, tx.Hash(), block.Transactions()[0].Hash())
+		}
+	})
+
+	t.Run("manual", func(t *testing.T) {
+		backend := bms.NewBacked(t, bms.WithManualMining())
+		require.Equal(t, bms.ManualMine, backend.MiningMode())
+
+		eoa := bms.GetTEoa(t)
+		tx1, err := bmsutils.SendDynamicTx(backend, backend.Owner, &eoa.From, []byte{})
+		require.NoError(t, err)
+		tx2, err := bmsutils.SendDynamicTx(backend, backend.Owner, &eoa.From, []byte{})
+		require.NoError(t, err)
+
+		_, err = backend.TransactionReceipt(ctx, tx1.Hash())
+		require.Error(t, err)
+
+		backend.Mine(1)
+		receipt1, err := backend.TransactionReceipt(ctx, tx1.Hash())
+		require.NoError(t, err)
+		receipt2, err := backend.TransactionReceipt(ctx, tx2.Hash())
+		require.NoError(t, err)
+		require.Equal(t, receipt1.BlockHash, receipt2.BlockHash)
+		require.Equal(t, uint(0), receipt1.TransactionIndex)
+		require.Equal(t, uint(1), receipt2.TransactionIndex)
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		backend := bms.NewBacked(t, bms.WithIntervalMining(100*time.Millisecond))
+		require.Equal(t, bms.IntervalMine, backend.MiningMode())
+
+		eoa := bms.GetTEoa(t)
+		txpool := bmsutils.NewTxPool(backend)
+		require.NoError(t, txpool.Exec(bmsutils.SendDynamicTx(backend, backend.Owner, &eoa.From, []byte{})))
+		require.NoError(t, txpool.AllReceiptStatusSuccessful(ctx))
+
+		backend.SetIntervalMining(0)
+		require.Equal(t, bms.ManualMine, backend.MiningMode())
+	})
+}
+
+func TestJSONRPC(t *testing.T) {
+	backend := bms.NewBacked(t, bms.WithHTTP("127.0.0.1", 0))
+	ctx := context.Background()
+
+	client, err := ethclient.DialContext(ctx, backend.HTTPEndpoint())
+	require.NoError(t, err)
+	defer client.Close()
+
+	// transactions sent over JSON-RPC are mined like those of SendTransaction
+	eoa := bms.GetTEoa(t)
+	backend.Owner.Value = bmsutils.ToWei(1)
+	tx, err := bmsutils.SendDynamicTx(client, backend.Owner, &eoa.From, []byte{})
+	backend.Owner.Value = common.Big0
+	require.NoError(t, err)
+
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	balance, err := backend.BalanceAt(ctx, eoa.From, nil)
+	require.NoError(t, err)
+	require.Equal(t, bmsutils.ToWei(1), balance)
+}
+
+func TestDataDir(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	eoa := bms.GetTEoa(t)
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+
+	backend := bms.NewBacked(t, bms.WithDataDir(dir))
+	backend.Owner.Value = bmsutils.ToWei(1)
+	_, err := bmsutils.SendDynamicTx(backend, backend.Owner, &eoa.From, []byte{})
+	backend.Owner.Value = common.Big0
+	require.NoError(t, err)
+	require.NoError(t, backend.SetCode(contract, []byte{byte(vm.STOP)}))
+	require.NoError(t, backend.SetStorageAt(contract, common.Hash{1}, common.Hash{2}))
+	head, err := backend.BlockNumber(ctx)
+	require.NoError(t, err)
+	require.NoError(t, backend.Close())
+
+	// the chain is resumed from the data directory
+	backend = bms.NewBacked(t, bms.WithDataDir(dir))
+	number, err := backend.BlockNumber(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, number)
+	balance, err := backend.BalanceAt(ctx, eoa.From, nil)
+	require.NoError(t, err)
+	require.Equal(t, bmsutils.ToWei(1), balance)
+	backend.Commit()
+
+	// and its state can seed a new chain
+	alloc, err := backend.DumpState()
+	require.NoError(t, err)
+	require.Contains(t, alloc, backend.Owner.From)
+	loaded := bms.NewBacked(t, bms.WithGenesisAlloc(alloc))
+	balance, err = loaded.BalanceAt(ctx, eoa.From, nil)
+	require.NoError(t, err)
+	require.Equal(t, bmsutils.ToWei(1), balance)
+	code, err := loaded.CodeAt(ctx, contract, nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte{byte(vm.STOP)}, code)
+	value, err := loaded.StorageAt(ctx, contract, common.Hash{1}, nil)
+	require.NoError(t, err)
+	require.Equal(t, common.Hash{2}.Bytes(), value)
+
+	// a backend failing to open the chain releases the data directory
+	broken := t.TempDir()
+	chaindata := filepath.Join(broken, "bms", "chaindata")
+	require.NoError(t, os.MkdirAll(filepath.Dir(chaindata), 0755))
+	require.NoError(t, os.WriteFile(chaindata, nil, 0644))
+	_, err = bms.NewBackend(bms.WithDataDir(broken))
+	require.Error(t, err)
+	require.NoError(t, os.Remove(chaindata))
+	bms.NewBacked(t, bms.WithDataDir(broken))
+}
@@ -0,0 +1,70 @@
+package bms
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownSnapshot is returned by Revert for ids that were not taken or already used.
+var ErrUnknownSnapshot = errors.New("unknown snapshot")
+
+// snapshot is the chain at the time Backend.Snapshot was taken.
+type snapshot struct {
+	head           common.Hash
+	nextTimestamp  uint64
+	timeOffset     int64
+	impersonations map[common.Address]*impersonation
+}
+
+// Snapshot saves the state of the chain and returns its id, for Revert.
+func (ec *Backend) Snapshot() uint64 {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	ec.snapshots = append(ec.snapshots, snapshot{
+		head:           ec.eth.BlockChain().CurrentBlock().Hash(),
+		nextTimestamp:  ec.nextTimestamp,
+		timeOffset:     ec.timeOffset,
+		impersonations: copyImpersonations(ec.impersonations),
+	})
+	return uint64(len(ec.snapshots))
+}
+
+// Revert brings the chain back to the snapshot, dropping the pending transactions and
+// the blocks sealed since. The snapshot and those taken after it can't be used again.
+// Accounts impersonated at the snapshot are restored in a new block.
+func (ec *Backend) Revert(id uint64) error {
+	if ec.fork != nil {
+		ec.fork.lock.Lock()
+		defer ec.fork.lock.Unlock()
+	}
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	if id == 0 || id > uint64(len(ec.snapshots)) {
+		return errors.Wrapf(ErrUnknownSnapshot, "snapshot %d", id)
+	}
+	target := ec.snapshots[id-1]
+	ec.snapshots = ec.snapshots[:id-1]
+
+	ec.flushTxPool()
+	if err := ec.beacon.Fork(target.head); err != nil {
+		return err
+	}
+	ec.nextTimestamp = target.nextTimestamp
+	ec.timeOffset = target.timeOffset
+	ec.impersonations = target.impersonations
+	if ec.fork != nil {
+		ec.fork.rewind(ec.eth.BlockChain().CurrentBlock().Number.Uint64())
+	}
+	return ec.dropImpersonations()
+}
+
+// flushTxPool drops the pending transactions. Expects commitLock to be held.
+func (ec *Backend) flushTxPool() {
+	maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1)
+	ec.eth.TxPool().SetGasTip(maxUint256)
+	ec.eth.TxPool().SetGasTip(ec.gasTip)
+}
@@ -0,0 +1,150 @@
+package bms
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+// writeState writes the state as an empty block on top of parent, which is how the
+// state of the chain is changed outside of transactions. The block keeps the timestamp
+// of its parent so the clock of the chain does not move. Expects commitLock to be held.
+func (ec *Backend) writeState(parent *types.Header, statedb *state.StateDB) error {
+	chain := ec.eth.BlockChain()
+	config := chain.Config()
+
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Coinbase:   parent.Coinbase,
+		Root:       statedb.IntermediateRoot(config.IsEIP158(parent.Number)),
+		Difficulty: new(big.Int),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time,
+		MixDigest:  parent.MixDigest,
+		BaseFee:    parent.BaseFee,
+	}
+	var withdrawals []*types.Withdrawal
+	if config.IsShanghai(header.Number, header.Time) {
+		withdrawals = make([]*types.Withdrawal, 0)
+	}
+	block := types.NewBlockWithWithdrawals(header, nil, nil, nil, withdrawals, trie.NewStackTrie(nil))
+	if _, err := chain.WriteBlockAndSetHead(block, nil, nil, statedb, true); err != nil {
+		return err
+	}
+	// let the pool see the new nonces and balances before the next transaction
+	return ec.eth.TxPool().Sync()
+}
+
+// SetBalance sets the balance of the account. Like every change of the state outside of
+// transactions, it mines an empty block, see writeState.
+func (ec *Backend) SetBalance(address common.Address, balance *big.Int) error {
+	return ec.setBalances([]common.Address{address}, balance)
+}
+
+// setBalances sets the balance of the accounts in a single block, see setState.
+func (ec *Backend) setBalances(addresses []common.Address, balance *big.Int) error {
+	amount, overflow := uint256.FromBig(balance)
+	if overflow || balance.Sign() < 0 {
+		return fmt.Errorf("invalid balance %v", balance)
+	}
+	for _, address := range addresses {
+		if err := ec.forkLoadAccount(context.Background(), nil, address); err != nil {
+			return err
+		}
+	}
+
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	head := ec.eth.BlockChain().CurrentBlock()
+	statedb, err := ec.eth.BlockChain().StateAt(head.Root)
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		statedb.SetBalance(address, amount)
+	}
+	return ec.writeState(head, statedb)
+}
+
+// SetNonce sets the nonce of the account, mining an empty block.
+func (ec *Backend) SetNonce(address common.Address, nonce uint64) error {
+	return ec.setState(address, nil, func(statedb *state.StateDB) {
+		statedb.SetNonce(address, nonce)
+	})
+}
+
+// SetCode sets the code of the account, keeping its storage. It mines an empty block.
+func (ec *Backend) SetCode(address common.Address, code []byte) error {
+	return ec.setState(address, nil, func(statedb *state.StateDB) {
+		statedb.SetCode(address, code)
+	})
+}
+
+// SetStorageAt sets a storage slot of the account, mining an empty block.
+func (ec *Backend) SetStorageAt(address common.Address, key, value common.Hash) error {
+	return ec.setState(address, []common.Hash{key}, func(statedb *state.StateDB) {
+		statedb.SetState(address, key, value)
+	})
+}
+
+// setState changes the state of the account in a new block, see writeState. In fork mode
+// the account is loaded first, so the state it does not change stays the remote one.
+func (ec *Backend) setState(address common.Address, keys []common.Hash, change func(*state.StateDB)) error {
+	if err := ec.forkLoadAccount(context.Background(), nil, address, keys...); err != nil {
+		return err
+	}
+
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	head := ec.eth.BlockChain().CurrentBlock()
+	statedb, err := ec.eth.BlockChain().StateAt(head.Root)
+	if err != nil {
+		return err
+	}
+	change(statedb)
+	return ec.writeState(head, statedb)
+}
+
+// DumpState returns the accounts of the latest state as a genesis allocation, e.g. to
+// start another chain from it with WithGenesisAlloc. In fork mode only the state fetched
+// so far is included.
+func (ec *Backend) DumpState() (core.GenesisAlloc, error) {
+	ec.commitLock.Lock()
+	defer ec.commitLock.Unlock()
+
+	statedb, err := ec.eth.BlockChain().StateAt(ec.eth.BlockChain().CurrentBlock().Root)
+	if err != nil {
+		return nil, err
+	}
+	// accounts and slots are found through the preimages of their hashes, see newBackend
+	dump := statedb.RawDump(&state.DumpConfig{OnlyWithAddresses: true})
+	if dump.Next != nil {
+		return nil, fmt.Errorf("incomplete state dump")
+	}
+	alloc := make(core.GenesisAlloc, len(dump.Accounts))
+	for address, account := range dump.Accounts {
+		balance, ok := new(big.Int).SetString(account.Balance, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid balance %q of %s", account.Balance, address)
+		}
+		dumped := core.GenesisAccount{Balance: balance, Nonce: account.Nonce, Code: account.Code}
+		if len(account.Storage) != 0 {
+			dumped.Storage = make(map[common.Hash]common.Hash, len(account.Storage))
+			for key, value := range account.Storage {
+				dumped.Storage[key] = common.HexToHash(value)
+			}
+		}
+		alloc[common.HexToAddress(address)] = dumped
+	}
+	return alloc, nil
+}
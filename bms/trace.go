@@ -0,0 +1,116 @@
+package bms
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var callTracer = map[string]interface{}{"tracer": "callTracer"}
+
+// TraceTransaction returns the call tree of a mined transaction.
+func (ec *Backend) TraceTransaction(ctx context.Context, txHash common.Hash) (*bmsutils.CallFrame, error) {
+	var frame *bmsutils.CallFrame
+	if err := ec.rpc.CallContext(ctx, &frame, "debug_traceTransaction", txHash, callTracer); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// TraceCall returns the call tree of the call executed on top of the latest block.
+func (ec *Backend) TraceCall(ctx context.Context, call ethereum.CallMsg) (*bmsutils.CallFrame, error) {
+	return ec.traceCall(ctx, call, "latest")
+}
+
+func (ec *Backend) traceCall(ctx context.Context, call ethereum.CallMsg, block string) (*bmsutils.CallFrame, error) {
+	config := callTracer
+	if ec.fork != nil && block == "latest" {
+		overrides, err := ec.forkCallState(ctx, call)
+		if err != nil {
+			return nil, err
+		}
+		config = map[string]interface{}{"tracer": "callTracer", "stateOverrides": overrides}
+	}
+	var frame *bmsutils.CallFrame
+	if err := ec.rpc.CallContext(ctx, &frame, "debug_traceCall", toCallArg(call), block, config); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// traceBlock returns the call trees of all the transactions in the block.
+func (ec *Backend) traceBlock(ctx context.Context, blockHash common.Hash) ([]*bmsutils.CallFrame, error) {
+	var results []struct {
+		Result *bmsutils.CallFrame `json:"result"`
+		Error  string              `json:"error"`
+	}
+	if err := ec.rpc.CallContext(ctx, &results, "debug_traceBlockByHash", blockHash, callTracer); err != nil {
+		return nil, err
+	}
+	frames := make([]*bmsutils.CallFrame, len(results))
+	for i, result := range results {
+		if result.Error != "" {
+			return nil, errors.New(result.Error)
+		}
+		frames[i] = result.Result
+	}
+	return frames, nil
+}
+
+func (ec *Backend) printConsoleLogs(frame *bmsutils.CallFrame) {
+	if ec.logger == nil {
+		return
+	}
+	for _, log := range frame.ConsoleLogs() {
+		ec.logger.Log(log)
+	}
+}
+
+func toTraceError(frame *bmsutils.CallFrame) error {
+	err := bmsutils.UnpackRevert(frame.Output)
+	if err == nil {
+		err = errors.New(frame.Reason())
+	}
+	return &bmsutils.TraceError{Err: err, Trace: frame}
+}
+
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(number)
+}
+
+func toCallArg(msg ethereum.CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["input"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	if msg.GasFeeCap != nil {
+		arg["maxFeePerGas"] = (*hexutil.Big)(msg.GasFeeCap)
+	}
+	if msg.GasTipCap != nil {
+		arg["maxPriorityFeePerGas"] = (*hexutil.Big)(msg.GasTipCap)
+	}
+	if msg.AccessList != nil {
+		arg["accessList"] = msg.AccessList
+	}
+	return arg
+}
@@ -0,0 +1,227 @@
+package bms_test
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	innerABI = `[{"type":"function","name":"fail","inputs":[{"name":"x","type":"uint256"}],"outputs":[],"stateMutability":"nonpayable"},{"type":"error","name":"Boom","inputs":[{"name":"x","type":"uint256"}]}]`
+	outerABI = `[{"type":"function","name":"call","inputs":[{"name":"inner","type":"address"},{"name":"x","type":"uint256"}],"outputs":[],"stateMutability":"nonpayable"}]`
+)
+
+// innerCode reverts every call with Boom(x), x being the first argument.
+func innerCode(aBI *abi.ABI) []byte {
+	boom := aBI.Errors["Boom"].ID
+	return program(
+		push(boom[:4]), push([]byte{0xe0}), op(vm.SHL), push([]byte{0}), op(vm.MSTORE),
+		push([]byte{4}), op(vm.CALLDATALOAD), push([]byte{4}), op(vm.MSTORE),
+		push([]byte{0x24}), push([]byte{0}), op(vm.REVERT),
+	)
+}
+
+// outerCode calls inner.fail(x) and bubbles up its revert data.
+func outerCode(inner *abi.ABI) []byte {
+	body := program(
+		push(inner.Methods["fail"].ID), push([]byte{0xe0}), op(vm.SHL), push([]byte{0}), op(vm.MSTORE),
+		push([]byte{0x24}), op(vm.CALLDATALOAD), push([]byte{4}), op(vm.MSTORE),
+		push([]byte{0}), push([]byte{0}), push([]byte{0x24}), push([]byte{0}), push([]byte{0}),
+		push([]byte{4}), op(vm.CALLDATALOAD), op(vm.GAS), op(vm.CALL),
+	)
+	revert := program(
+		op(vm.RETURNDATASIZE), push([]byte{0}), push([]byte{0}), op(vm.RETURNDATACOPY),
+		op(vm.RETURNDATASIZE), push([]byte{0}), op(vm.REVERT),
+	)
+	dest := byte(len(body) + 3 + len(revert))
+	return program(body, push([]byte{dest}), op(vm.JUMPI), revert, op(vm.JUMPDEST), op(vm.STOP))
+}
+
+// deployCode wraps runtime code with a constructor returning it.
+func deployCode(runtime []byte) []byte {
+	size := byte(len(runtime))
+	return program(
+		push([]byte{size}), push([]byte{12}), push([]byte{0}), op(vm.CODECOPY),
+		push([]byte{size}), push([]byte{0}), op(vm.RETURN), runtime,
+	)
+}
+
+func push(data []byte) []byte {
+	return append([]byte{byte(vm.PUSH1) + byte(len(data)-1)}, data...)
+}
+
+func op(code vm.OpCode) []byte {
+	return []byte{byte(code)}
+}
+
+func program(parts ...[]byte) []byte {
+	code := make([]byte, 0)
+	for _, part := range parts {
+		code = append(code, part...)
+	}
+	return code
+}
+
+func TestTracing(t *testing.T) {
+	backend := bms.NewBacked(t, bms.WithTracing())
+	ctx := context.Background()
+
+	inner, err := abi.JSON(strings.NewReader(innerABI))
+	require.NoError(t, err)
+	outer, err := abi.JSON(strings.NewReader(outerABI))
+	require.NoError(t, err)
+
+	innerAddr, _, _, err := bind.DeployContract(backend.Owner, inner, deployCode(innerCode(&inner)), backend)
+	require.NoError(t, err)
+	outerAddr, _, outerContract, err := bind.DeployContract(backend.Owner, outer, deployCode(outerCode(&inner)), backend)
+	require.NoError(t, err)
+	bmsutils.EnrollContract(innerAddr, "Inner", &inner)
+	bmsutils.EnrollContract(outerAddr, "Outer", &outer)
+	bmsutils.EnrollErrors(&inner)
+
+	t.Run("estimate", func(t *testing.T) {
+		_, err := outerContract.Transact(backend.Owner, "call", innerAddr, big.NewInt(7))
+		require.Error(t, err)
+
+		var traceErr *bmsutils.TraceError
+		require.ErrorAs(t, err, &traceErr)
+		var revertErr *bmsutils.RevertError
+		require.ErrorAs(t, err, &revertErr)
+		t.Log(err)
+
+		require.Contains(t, err.Error(), "Outer.call(")
+		require.Contains(t, err.Error(), "Inner.fail(7) <- reverted here: Boom[7]")
+		require.Equal(t, innerAddr, *traceErr.Trace.RevertPoint().To)
+	})
+
+	t.Run("mined", func(t *testing.T) {
+		opts := *backend.Owner
+		opts.GasLimit = 100000
+		_, err := outerContract.Transact(&opts, "call", innerAddr, big.NewInt(8))
+		require.Error(t, err)
+
+		var traceErr *bmsutils.TraceError
+		require.ErrorAs(t, err, &traceErr)
+		require.Contains(t, err.Error(), "Inner.fail(8) <- reverted here: Boom[8]")
+
+		head, err := backend.BlockByNumber(ctx, nil)
+		require.NoError(t, err)
+		require.Len(t, head.Transactions(), 1)
+
+		frame, err := backend.TraceTransaction(ctx, head.Transactions()[0].Hash())
+		require.NoError(t, err)
+		require.True(t, frame.Failed())
+		require.Equal(t, outerAddr, *frame.To)
+	})
+}
+
+func TestTracingEnv(t *testing.T) {
+	t.Setenv(bms.TracingEnv, "1")
+	backend := bms.NewBacked(t)
+
+	inner, err := abi.JSON(strings.NewReader(innerABI))
+	require.NoError(t, err)
+	_, _, innerContract, err := bind.DeployContract(backend.Owner, inner, deployCode(innerCode(&inner)), backend)
+	require.NoError(t, err)
+
+	_, err = innerContract.Transact(backend.Owner, "fail", big.NewInt(1))
+	var traceErr *bmsutils.TraceError
+	require.ErrorAs(t, err, &traceErr)
+}
+
+// consoleCode logs the first 32 bytes of the calldata with console.log(uint256).
+func consoleCode() []byte {
+	return program(
+		push(crypto.Keccak256([]byte("log(uint256)"))[:4]), push([]byte{0xe0}), op(vm.SHL), push([]byte{0}), op(vm.MSTORE),
+		push([]byte{0}), op(vm.CALLDATALOAD), push([]byte{4}), op(vm.MSTORE),
+		push([]byte{0}), push([]byte{0}), push([]byte{0x24}), push([]byte{0}), push(bmsutils.ConsoleAddress.Bytes()),
+		op(vm.GAS), op(vm.STATICCALL), op(vm.POP), op(vm.STOP),
+	)
+}
+
+func TestConsoleLog(t *testing.T) {
+	r := &recorder{TB: t}
+	backend := bms.NewBacked(t, bms.WithCallConsoleLog(), bms.WithLogger(r))
+	ctx := context.Background()
+	txpool := bmsutils.NewTxPool(backend)
+
+	tx, err := bmsutils.SendDynamicTx(backend, backend.Owner, nil, deployCode(consoleCode()))
+	require.NoError(t, txpool.Exec(tx, err))
+	receipts, err := txpool.WaitMined(ctx)
+	require.NoError(t, err)
+	logger := receipts[0].ContractAddress
+	r.logs = nil
+
+	input := common.LeftPadBytes(big.NewInt(42).Bytes(), 32)
+	_, err = backend.CallContract(ctx, ethereum.CallMsg{From: backend.Owner.From, To: &logger, Data: input}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"42"}, r.logs)
+	r.logs = nil
+
+	// a fixed gas limit skips the estimation, which would print the message too
+	opts := *backend.Owner
+	opts.GasLimit = 100000
+	tx, err = bmsutils.SendDynamicTx(backend, &opts, &logger, input)
+	require.NoError(t, txpool.Exec(tx, err))
+	require.NoError(t, txpool.AllReceiptStatusSuccessful(ctx))
+	require.Equal(t, []string{"42"}, r.logs)
+
+	frame, err := backend.TraceTransaction(ctx, tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, []string{"42"}, frame.ConsoleLogs())
+
+	stringType, _ := abi.NewType("string", "", nil)
+	uintType, _ := abi.NewType("uint256", "", nil)
+	data, err := abi.Arguments{{Type: stringType}, {Type: uintType}}.Pack("balance", big.NewInt(100))
+	require.NoError(t, err)
+	log, err := bmsutils.UnpackConsoleLog(append(crypto.Keccak256([]byte("log(string,uint256)"))[:4], data...))
+	require.NoError(t, err)
+	require.Equal(t, "balance 100", log)
+
+	// console.sol before hardhat 2.10 wrote uint256 as uint in the signatures.
+	log, err = bmsutils.UnpackConsoleLog(append(crypto.Keccak256([]byte("log(string,uint)"))[:4], data...))
+	require.NoError(t, err)
+	require.Equal(t, "balance 100", log)
+
+	// the format specifiers of the first string are substituted like hardhat does
+	for _, test := range []struct {
+		signature string
+		args      []interface{}
+		log       string
+	}{
+		{"log(string,uint256)", []interface{}{"x=%d", big.NewInt(42)}, "x=42"},
+		{"log(string,string,uint256)", []interface{}{"%s has %i", "alice", big.NewInt(3)}, "alice has 3"},
+		{"log(string,uint256,uint256)", []interface{}{"x=%d", big.NewInt(1), big.NewInt(2)}, "x=1 2"},
+		{"log(string,uint256)", []interface{}{"%d%% of %d", big.NewInt(5)}, "5% of %d"},
+		{"log(string,string,string)", []interface{}{"%c%o", "color", "object"}, "object"},
+		{"log(uint256,string)", []interface{}{big.NewInt(5), "%d"}, "5 %d"},
+	} {
+		data, err := consoleArgs(t, test.signature).Pack(test.args...)
+		require.NoError(t, err, test.signature)
+		log, err := bmsutils.UnpackConsoleLog(append(crypto.Keccak256([]byte(test.signature))[:4], data...))
+		require.NoError(t, err)
+		require.Equal(t, test.log, log, test.signature)
+	}
+}
+
+// consoleArgs returns the arguments of the console.sol signature.
+func consoleArgs(t *testing.T, signature string) abi.Arguments {
+	var args abi.Arguments
+	for _, param := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(signature, "log("), ")"), ",") {
+		typ, err := abi.NewType(param, "", nil)
+		require.NoError(t, err)
+		args = append(args, abi.Argument{Type: typ})
+	}
+	return args
+}
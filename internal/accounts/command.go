@@ -0,0 +1,367 @@
+package accounts
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/bang9ming9/go-hardhat/internal/utils"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	hdwallet "github.com/miguelmota/go-ethereum-hdwallet"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	COUNT_FLAG_NAME        string = "count"
+	NETWORK_FLAG_NAME      string = "network"
+	RPC_URL_FLAG_NAME      string = "rpc-url"
+	YES_FLAG_NAME          string = "yes"
+	DIR_FLAG_NAME          string = "dir"
+	PASSWORD_ENV_FLAG_NAME string = "password-env"
+	KEY_ENV_FLAG_NAME      string = "key-env"
+	FILE_FLAG_NAME         string = "file"
+
+	// defaultKeystoreDir is the directory of the keystore files, under the root.
+	defaultKeystoreDir string = "keys"
+	// defaultMnemonicFile is the file rotate writes to, under the home directory, unless
+	// the wallet reads another one.
+	defaultMnemonicFile string = ".bms-mnemonic"
+)
+
+var (
+	yesFlag = &cli.BoolFlag{
+		Name:    YES_FLAG_NAME,
+		Aliases: []string{"y"},
+		Usage:   "confirm without prompting",
+	}
+	keystoreFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:  DIR_FLAG_NAME,
+			Usage: "directory of the keystore file (default: keys under the root)",
+		}, &cli.StringFlag{
+			Name:  PASSWORD_ENV_FLAG_NAME,
+			Usage: "variable holding the password of the keystore file, prompted if unset",
+		},
+	}
+)
+
+var Command *cli.Command = &cli.Command{
+	Name:  "accounts",
+	Usage: "Manage the accounts of the wallet the tests, backends and nodes use",
+	Before: func(ctx *cli.Context) error {
+		// --root 프로젝트의 bms.json 의 wallet 설정을 사용한다.
+		if os.Getenv(bms.ConfigFileEnv) == "" && utils.SetDirPath() == nil {
+			return os.Setenv(bms.ConfigFileEnv, utils.GetConfigFilePath())
+		}
+		return nil
+	},
+	Subcommands: []*cli.Command{
+		{
+			Name:  "list",
+			Usage: "List the accounts of the wallet and their balances",
+			Flags: []cli.Flag{
+				&cli.UintFlag{
+					Name:    COUNT_FLAG_NAME,
+					Aliases: []string{"n"},
+					Value:   10,
+					Usage:   "number of accounts",
+				}, &cli.StringFlag{
+					Name:  NETWORK_FLAG_NAME,
+					Value: bms.DefaultNetwork,
+					Usage: "network profile of bms.json to read the balances from, localhost being a local bms node",
+				}, &cli.StringFlag{
+					Name:  RPC_URL_FLAG_NAME,
+					Usage: "JSON-RPC endpoint overriding the one of the network",
+				},
+			},
+			Action: list,
+		}, {
+			Name:      "key",
+			Usage:     "Print the private key of an account of the wallet",
+			ArgsUsage: "<index or name>",
+			Flags:     []cli.Flag{yesFlag},
+			Action:    printKey,
+		}, {
+			Name:      "export",
+			Usage:     "Write an account of the wallet to an encrypted keystore file",
+			ArgsUsage: "<index or name>",
+			Flags:     keystoreFlags,
+			Action:    export,
+		}, {
+			Name:  "import",
+			Usage: "Write a private key to an encrypted keystore file, e.g. the signer of a network",
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:  KEY_ENV_FLAG_NAME,
+					Usage: "variable holding the hex private key, prompted if unset",
+				},
+			}, keystoreFlags...),
+			Action: importKey,
+		}, {
+			Name:  "rotate",
+			Usage: "Write a new mnemonic to the mnemonic file of the wallet (default ~/.bms-mnemonic)",
+			Flags: []cli.Flag{
+				yesFlag,
+				&cli.StringFlag{
+					Name:  FILE_FLAG_NAME,
+					Usage: "mnemonic file to write, instead of the one of the wallet",
+				},
+			},
+			Action: rotate,
+		},
+	},
+}
+
+func list(ctx *cli.Context) error {
+	if file, err := bms.MnemonicFile(); err != nil {
+		return errors.Wrap(err, "bms.MnemonicFile")
+	} else if file != "" {
+		fmt.Println("Mnemonic file", file)
+	}
+
+	// 1. 잔액을 조회할 네트워크 연결, 실패하면 주소만 출력
+	client, err := dial(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: no balances,", err)
+	} else {
+		defer client.Close()
+	}
+
+	// 2. 계정 출력
+	for i := uint32(0); i < uint32(ctx.Uint(COUNT_FLAG_NAME)); i++ {
+		key, err := bms.GetKeyAt(i)
+		if err != nil {
+			return errors.Wrap(err, "bms.GetKeyAt")
+		}
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		line := fmt.Sprintf("[%d] %s %s", i, address.Hex(), walletPath(i))
+		if client != nil {
+			if balance, err := client.BalanceAt(ctx.Context, address, nil); err != nil {
+				line += " " + err.Error()
+			} else {
+				line += fmt.Sprintf(" %s ETH", bmsutils.ToEther(balance))
+			}
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// dial connects to the network of the flags, like bms run does.
+func dial(ctx *cli.Context) (*ethclient.Client, error) {
+	conf, err := bms.ReadConfig(os.Getenv(bms.ConfigFileEnv))
+	if err != nil {
+		return nil, errors.Wrap(err, "bms.ReadConfig")
+	}
+	network, err := conf.Network(ctx.String(NETWORK_FLAG_NAME))
+	if err != nil {
+		return nil, err
+	}
+	if url := ctx.String(RPC_URL_FLAG_NAME); url != "" {
+		copied := *network
+		copied.URL = url
+		network = &copied
+	}
+	dialCtx, cancel := context.WithTimeout(ctx.Context, 5*time.Second)
+	defer cancel()
+	client, _, err := network.Dial(dialCtx)
+	return client, err
+}
+
+func walletPath(index uint32) accounts.DerivationPath {
+	return append(accounts.DefaultRootDerivationPath[:len(accounts.DefaultRootDerivationPath):len(accounts.DefaultRootDerivationPath)], index)
+}
+
+// walletKey returns the key of the argument, the index of an account of the wallet or
+// the name of a named account (see bms.Account).
+func walletKey(ctx *cli.Context) (*ecdsa.PrivateKey, string, error) {
+	if ctx.NArg() != 1 {
+		return nil, "", fmt.Errorf("expected the index or the name of the account, e.g. bms accounts %s 0", ctx.Command.Name)
+	}
+	arg := ctx.Args().First()
+	if index, err := strconv.ParseUint(arg, 10, 31); err == nil {
+		key, err := bms.GetKeyAt(uint32(index))
+		return key, walletPath(uint32(index)).String(), errors.Wrap(err, "bms.GetKeyAt")
+	}
+	key, err := bms.AccountKey(arg)
+	return key, "account " + arg, errors.Wrap(err, "bms.AccountKey")
+}
+
+func printKey(ctx *cli.Context) error {
+	key, name, err := walletKey(ctx)
+	if err != nil {
+		return err
+	}
+	if !ctx.Bool(YES_FLAG_NAME) {
+		return fmt.Errorf("the private key is printed in plain text, confirm with --%s", YES_FLAG_NAME)
+	}
+	fmt.Println("Account    ", name)
+	fmt.Println("Address    ", crypto.PubkeyToAddress(key.PublicKey).Hex())
+	fmt.Println("Private key", hexutil.Encode(crypto.FromECDSA(key)))
+	return nil
+}
+
+func export(ctx *cli.Context) error {
+	key, _, err := walletKey(ctx)
+	if err != nil {
+		return err
+	}
+	return writeKeystore(ctx, key)
+}
+
+func importKey(ctx *cli.Context) error {
+	var hex string
+	if name := ctx.String(KEY_ENV_FLAG_NAME); name != "" {
+		if hex = os.Getenv(name); hex == "" {
+			return fmt.Errorf("%s is not set", name)
+		}
+	} else {
+		var err error
+		if hex, err = prompt.Stdin.PromptPassword("Private key: "); err != nil {
+			return err
+		}
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(hex), "0x"))
+	if err != nil {
+		return errors.Wrap(err, "private key")
+	}
+	return writeKeystore(ctx, key)
+}
+
+// writeKeystore encrypts the key to a keystore file, the signer of a network reading it
+// with the password of passwordEnv.
+func writeKeystore(ctx *cli.Context, key *ecdsa.PrivateKey) error {
+	// 1. 비밀번호
+	passwordEnv := ctx.String(PASSWORD_ENV_FLAG_NAME)
+	var password string
+	if passwordEnv != "" {
+		var ok bool
+		if password, ok = os.LookupEnv(passwordEnv); !ok {
+			return fmt.Errorf("%s is not set", passwordEnv)
+		}
+	} else {
+		var err error
+		if password, err = prompt.Stdin.PromptPassword("Password: "); err != nil {
+			return err
+		}
+		if confirm, err := prompt.Stdin.PromptPassword("Repeat password: "); err != nil {
+			return err
+		} else if confirm != password {
+			return errors.New("the passwords do not match")
+		}
+	}
+
+	// 2. keystore 파일 생성
+	dir := ctx.String(DIR_FLAG_NAME)
+	if dir == "" {
+		rootpath, err := utils.GetRootPath()
+		if err != nil {
+			return errors.Wrap(err, "utils.GetRootPath")
+		}
+		dir = filepath.Join(rootpath, defaultKeystoreDir)
+	}
+	account, err := keystore.NewKeyStore(dir, keystore.StandardScryptN, keystore.StandardScryptP).ImportECDSA(key, password)
+	if err != nil {
+		return errors.Wrap(err, "keystore")
+	}
+
+	fmt.Println("Address ", account.Address.Hex())
+	fmt.Println("Keystore", account.URL.Path)
+	path := account.URL.Path
+	if rootpath, err := utils.GetRootPath(); err == nil {
+		if rel, err := filepath.Rel(rootpath, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = filepath.ToSlash(rel)
+		}
+	}
+	if passwordEnv == "" {
+		passwordEnv = "<PASSWORD_ENV>"
+	}
+	fmt.Printf("bms.json signer: {\"keystore\": %q, \"passwordEnv\": %q}\n", path, passwordEnv)
+	return nil
+}
+
+func rotate(ctx *cli.Context) error {
+	// 1. 니모닉 파일 경로
+	configured, err := bms.MnemonicFile()
+	if err != nil {
+		return errors.Wrap(err, "bms.MnemonicFile")
+	}
+	path := ctx.String(FILE_FLAG_NAME)
+	if path == "" {
+		path = configured
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(home, defaultMnemonicFile)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	// 2. 기존 파일은 백업한다.
+	if previous, err := os.ReadFile(path); err == nil {
+		if !ctx.Bool(YES_FLAG_NAME) {
+			if ok, err := prompt.Stdin.PromptConfirm(fmt.Sprintf("Replace the mnemonic of %s?", path)); err != nil {
+				return err
+			} else if !ok {
+				return errors.New("canceled")
+			}
+		}
+		backup := fmt.Sprintf("%s.%d.bak", path, time.Now().UnixNano())
+		if err := os.WriteFile(backup, previous, 0600); err != nil {
+			return errors.Wrap(err, "backup")
+		}
+		fmt.Println("Previous mnemonic backed up to", backup)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// 3. 새 니모닉 기록, 본인만 읽을 수 있도록 0600
+	mnemonic, err := hdwallet.NewMnemonic(128)
+	if err != nil {
+		return err
+	}
+	wallet, err := hdwallet.NewFromMnemonic(mnemonic)
+	if err != nil {
+		return err
+	}
+	owner, err := wallet.Derive(walletPath(0), false)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(mnemonic+"\n"), 0600); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+	fmt.Println("Mnemonic written to", path)
+	fmt.Println("Owner", owner.Address.Hex())
+
+	// 4. 지갑이 읽는 파일이 아니면 설정 방법 안내
+	if os.Getenv(bms.MnemonicEnv) != "" {
+		fmt.Fprintf(os.Stderr, "warning: %s is set and used instead of the file\n", bms.MnemonicEnv)
+	} else if path != configured {
+		fmt.Printf("The wallet reads it once %s is set or bms.json has {\"wallet\": {\"mnemonicFile\": %q}}\n", bms.MnemonicFileEnv, path)
+	}
+	return nil
+}
@@ -42,78 +42,209 @@ var Command *cli.Command = &cli.Command{
 	},
 	Action: func(ctx *cli.Context) error {
 		if err := utils.SetDirPath(); err != nil {
-			return nil
+			return errors.Wrap(err, "utils.SetDirPath")
 		}
 
-		// 1. solc 버전 확인 및 설치
-		version, err := utils.ToSolcVersion(ctx.Args().First())
-		if err != nil {
-			return errors.Wrap(err, "utils.ToSolcVersion")
-		}
-		if err := utils.InstallSolc(version); err != nil {
-			return errors.Wrap(err, "utils.InstallSolc")
+		// 인자와 플래그가 없으면 마지막 컴파일 설정을 사용
+		if ctx.NArg() == 0 && ctx.NumFlags() == 0 {
+			if recorded, err := ReadOptions(); err == nil {
+				return Run(recorded)
+			}
 		}
 
-		// 2. solidity 컴파일 실행
-		// 2-1. 컴파일 제외할 디렉토리 확인
-		var excludes []string
+		opts := &Options{Version: ctx.Args().First(), Merge: ctx.Bool(MERGE_FLAG_NAME)}
 		if ctx.IsSet(EXCLUDE_FLAG_NAME) {
-			excludes = make([]string, 0)
-			for _, path := range strings.Split(ctx.String(EXCLUDE_FLAG_NAME), ",") {
-				if filepath.IsAbs(path) {
-					excludes = append(excludes, path)
-				} else if abs, err := filepath.Abs(path); err != nil {
-					return errors.Wrap(err, fmt.Sprintf("%s is invalid filepath", path))
-				} else {
-					excludes = append(excludes, abs)
-				}
-			}
-		}
-		// 2-2 컴파일 할 파일 목록 가져오기
-		files, err := findSolFiles(utils.GetContractDir(), excludes)
-		if err != nil {
-			return errors.Wrap(err, "findSolFiles")
+			opts.Excludes = strings.Split(ctx.String(EXCLUDE_FLAG_NAME), ",")
 		}
-		// 2-3. compile 실행 (solc-0.0.0 --optimize --combined-json abi,bin contracts/*.sol)
-		contracts, err := compile(version, utils.ReadRemappings(), files)
-		if err != nil {
-			return errors.Wrap(err, "compile")
+		if ctx.IsSet(FILTER_FLAG_NAME) {
+			opts.Filters = strings.Split(ctx.String(FILTER_FLAG_NAME), ",")
 		}
+		return Run(opts)
+	},
+}
 
-		// 3. filter 적용
-		if ctx.IsSet(FILTER_FLAG_NAME) {
-			filters := make(map[string]struct{})
-			for _, f := range strings.Split(ctx.String(FILTER_FLAG_NAME), ",") {
-				filters[f] = struct{}{}
-			}
-			for name := range contracts {
-				if _, ok := filters[name]; !ok {
-					delete(contracts, name)
-				}
+// Options are the settings of a compilation, recorded in abis/.compile.json so that
+// bms test can compile again with them.
+type Options struct {
+	Version string `json:"version"`
+	// Excludes are the paths excluded from the compilation, relative to the working
+	// directory or absolute. They are recorded relative to the root of the project.
+	Excludes []string `json:"excludes,omitempty"`
+	Filters  []string `json:"filters,omitempty"`
+	Merge    bool     `json:"merge,omitempty"`
+}
+
+// Run compiles the contracts and writes their bindings to abis.
+func Run(opts *Options) error {
+	// 1. solc 버전 확인 및 설치
+	version, err := utils.ToSolcVersion(opts.Version)
+	if err != nil {
+		return errors.Wrap(err, "utils.ToSolcVersion")
+	}
+	if err := utils.InstallSolc(version); err != nil {
+		return errors.Wrap(err, "utils.InstallSolc")
+	}
+
+	// 2. solidity 컴파일 실행
+	// 2-1. 컴파일 제외할 디렉토리 확인
+	excludes, err := opts.excludes()
+	if err != nil {
+		return err
+	}
+	// 2-2 컴파일 할 파일 목록 가져오기
+	files, err := findSolFiles(utils.GetContractDir(), excludes)
+	if err != nil {
+		return errors.Wrap(err, "findSolFiles")
+	}
+	// 2-3. compile 실행 (solc-0.0.0 --optimize --combined-json abi,bin contracts/*.sol)
+	contracts, err := compile(version, utils.ReadRemappings(), files)
+	if err != nil {
+		return errors.Wrap(err, "compile")
+	}
+
+	// 3. filter 적용
+	if len(opts.Filters) != 0 {
+		filters := make(map[string]struct{})
+		for _, f := range opts.Filters {
+			filters[f] = struct{}{}
+		}
+		for name := range contracts {
+			if _, ok := filters[name]; !ok {
+				delete(contracts, name)
 			}
 		}
+	}
 
-		// 4. abigen 실행
-		abisDir := utils.GetABIsDir()
-		if _, err := os.Stat(abisDir); errors.Is(err, os.ErrNotExist) {
-			if err := os.Mkdir(abisDir, 0755); err != nil {
-				return errors.Wrap(err, abisDir)
-			}
+	// 4. abigen 실행
+	abisDir := utils.GetABIsDir()
+	if _, err := os.Stat(abisDir); errors.Is(err, os.ErrNotExist) {
+		if err := os.Mkdir(abisDir, 0755); err != nil {
+			return errors.Wrap(err, abisDir)
 		}
+	}
 
-		if ctx.Bool(MERGE_FLAG_NAME) {
-			if err := abigenMerge(contracts); err != nil {
-				return errors.Wrap(err, "abigenMerge")
+	if opts.Merge {
+		if err := abigenMerge(contracts); err != nil {
+			return errors.Wrap(err, "abigenMerge")
+		}
+	} else {
+		for name, compiled := range contracts {
+			if err := abigen(name, compiled); err != nil {
+				return errors.Wrap(err, name)
 			}
-		} else {
-			for name, compiled := range contracts {
-				if err := abigen(name, compiled); err != nil {
-					return errors.Wrap(err, name)
-				}
+		}
+	}
+
+	// 5. 컴파일 설정 기록
+	return opts.record(version, excludes)
+}
+
+// Stale reports whether a contract source changed since the last compilation, or the
+// bindings are missing, returning the recorded options of the compilation. The options
+// are nil if the contracts were never compiled by this version of bms.
+func Stale() (*Options, bool, error) {
+	opts, err := ReadOptions()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	compiled, err := os.Stat(optionsPath())
+	if err != nil {
+		return nil, false, err
+	}
+	excludes, err := opts.excludes()
+	if err != nil {
+		return nil, false, err
+	}
+	stale, sources := false, 0
+	err = filepath.Walk(utils.GetContractDir(), func(path string, info os.FileInfo, err error) error {
+		if err != nil || stale {
+			return err
+		}
+		for _, exc := range excludes {
+			if strings.HasPrefix(path, exc) {
+				return nil
 			}
 		}
+		if filepath.Ext(path) == ".sol" {
+			sources++
+		}
+		if (filepath.Ext(path) == ".sol" || path == utils.GetRemappingsFilePath()) && info.ModTime().After(compiled.ModTime()) {
+			stale = true
+		}
 		return nil
-	},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	// bms init 템플릿처럼 설정만 있고 바인딩이 없는 경우
+	if bindings, _ := filepath.Glob(filepath.Join(utils.GetABIsDir(), "*.go")); len(bindings) == 0 && sources != 0 {
+		stale = true
+	}
+	return opts, stale, nil
+}
+
+// excludes returns the absolute paths of the excluded paths.
+func (opts *Options) excludes() ([]string, error) {
+	excludes := make([]string, 0, len(opts.Excludes))
+	for _, path := range opts.Excludes {
+		if filepath.IsAbs(path) {
+			excludes = append(excludes, path)
+		} else if abs, err := filepath.Abs(path); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("%s is invalid filepath", path))
+		} else {
+			excludes = append(excludes, abs)
+		}
+	}
+	return excludes, nil
+}
+
+func (opts *Options) record(version string, excludes []string) error {
+	recorded := *opts
+	if recorded.Version != "" { // 비어 있으면 PATH 의 solc
+		recorded.Version = version
+	}
+	recorded.Excludes = make([]string, 0, len(excludes))
+	for _, exc := range excludes {
+		if rel, err := filepath.Rel(rootDir(), exc); err == nil {
+			exc = rel
+		}
+		recorded.Excludes = append(recorded.Excludes, exc)
+	}
+	bytes, err := json.MarshalIndent(&recorded, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(optionsPath(), append(bytes, '\n'), 0644)
+}
+
+// ReadOptions reads the options recorded by the last compilation.
+func ReadOptions() (*Options, error) {
+	bytes, err := os.ReadFile(optionsPath())
+	if err != nil {
+		return nil, err
+	}
+	opts := new(Options)
+	if err := json.Unmarshal(bytes, opts); err != nil {
+		return nil, errors.Wrap(err, optionsPath())
+	}
+	// 기록된 경로는 프로젝트 루트 기준
+	for i, exc := range opts.Excludes {
+		if !filepath.IsAbs(exc) {
+			opts.Excludes[i] = filepath.Join(rootDir(), exc)
+		}
+	}
+	return opts, nil
+}
+
+// rootDir is the root of the project, where contracts and abis are.
+func rootDir() string {
+	return filepath.Dir(utils.GetContractDir())
+}
+
+func optionsPath() string {
+	return filepath.Join(utils.GetABIsDir(), ".compile.json")
 }
 
 type compiled struct {
@@ -0,0 +1,17 @@
+package deploy
+
+import (
+	"github.com/bang9ming9/go-hardhat/internal/run"
+	"github.com/urfave/cli/v2"
+)
+
+var Command *cli.Command = &cli.Command{
+	Name:      "deploy",
+	Usage:     "Run a Go deploy script and record the deployed contracts in deployments/<network>",
+	ArgsUsage: "<script> [script args...]",
+	Flags:     run.Flags,
+	Action: func(ctx *cli.Context) error {
+		// 배포 스크립트는 bms.DeployMain 으로 bms.Env 의 Deployer 를 사용한다.
+		return run.Script(ctx)
+	},
+}
@@ -0,0 +1,226 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bang9ming9/go-hardhat/internal/compile"
+	"github.com/bang9ming9/go-hardhat/internal/utils"
+	"github.com/fabelx/go-solc-select/pkg/versions"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+const FIX_FLAG_NAME string = "fix"
+
+var Command *cli.Command = &cli.Command{
+	Name:  "doctor",
+	Usage: "Check the go.mod versions, solc and layout of the project",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  FIX_FLAG_NAME,
+			Usage: "fix the problems that can be fixed",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if err := utils.SetDirPath(); err != nil {
+			return errors.Wrap(err, "utils.SetDirPath")
+		}
+		rootpath, err := utils.GetRootPath()
+		if err != nil {
+			return errors.Wrap(err, "utils.GetRootPath")
+		}
+
+		// 1. 검사 실행
+		results := make([]*finding, 0)
+		results = append(results, checkModules(rootpath)...)
+		results = append(results, checkLayout()...)
+		results = append(results, checkRemappings()...)
+		results = append(results, checkSolc()...)
+
+		// 2. 결과 출력 및 수정
+		problems := 0
+		for _, result := range results {
+			if result.problem == "" {
+				fmt.Printf("[ok]   %s\n", result.name)
+				continue
+			}
+			if ctx.Bool(FIX_FLAG_NAME) && result.fix != nil {
+				if err := result.fix(); err != nil {
+					fmt.Printf("[fail] %s: %s, fix failed: %v\n", result.name, result.problem, err)
+					problems++
+				} else {
+					fmt.Printf("[fix]  %s: %s, fixed\n", result.name, result.problem)
+				}
+				continue
+			}
+			hint := ""
+			if result.fix != nil {
+				hint = " (--fix)"
+			}
+			fmt.Printf("[fail] %s: %s%s\n", result.name, result.problem, hint)
+			problems++
+		}
+
+		if problems != 0 {
+			return fmt.Errorf("%d problems found", problems)
+		}
+		return nil
+	},
+}
+
+// finding is the result of a check, its problem empty if there is none. fix is nil if
+// the problem can't be fixed by bms doctor --fix.
+type finding struct {
+	name    string
+	problem string
+	fix     func() error
+}
+
+// checkModules checks the versions of go-hardhat and go-ethereum the go.mod requires
+// against the ones bms is built with.
+func checkModules(rootpath string) []*finding {
+	results := make([]*finding, 0, 2)
+	for _, module := range []struct{ path, version string }{
+		{utils.ModulePath, utils.AppVersion},
+		{utils.EthereumModulePath, utils.EthereumVersion},
+	} {
+		result := &finding{name: fmt.Sprintf("go.mod %s %s", module.path, module.version)}
+		results = append(results, result)
+
+		version, err := utils.ModuleVersion(module.path)
+		if err != nil {
+			result.problem = err.Error()
+			continue
+		}
+		if version == module.version || version == utils.LocalVersion {
+			continue
+		}
+		if version == "" {
+			result.problem = "not required"
+		} else {
+			result.problem = "requires " + version
+		}
+		target := module.path + "@" + module.version
+		result.fix = func() error {
+			cmd := exec.Command("go", "get", target)
+			cmd.Dir = rootpath
+			if output, err := cmd.CombinedOutput(); err != nil {
+				return errors.Wrap(err, strings.TrimSpace(string(output)))
+			}
+			return nil
+		}
+	}
+	return results
+}
+
+// checkLayout checks the directories and files bms init creates.
+func checkLayout() []*finding {
+	results := make([]*finding, 0, 4)
+	for _, dir := range []string{utils.GetContractDir(), utils.GetTestDir()} {
+		dir := dir
+		result := &finding{name: "directory " + filepath.Base(dir)}
+		if info, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			result.problem = "missing"
+			result.fix = func() error { return os.MkdirAll(dir, 0755) }
+		} else if err != nil {
+			result.problem = err.Error()
+		} else if !info.IsDir() {
+			result.problem = "not a directory"
+		}
+		results = append(results, result)
+	}
+
+	remappings := utils.GetRemappingsFilePath()
+	result := &finding{name: "file contracts/remappings.txt"}
+	if _, err := os.Stat(remappings); errors.Is(err, os.ErrNotExist) {
+		result.problem = "missing"
+		result.fix = func() error {
+			if err := os.MkdirAll(filepath.Dir(remappings), 0755); err != nil {
+				return err
+			}
+			return os.WriteFile(remappings, []byte{}, 0644)
+		}
+	} else if err != nil {
+		result.problem = err.Error()
+	}
+	results = append(results, result)
+
+	// test/base.go 는 go mod tidy 가 go-hardhat 의존성을 지우지 않도록 유지한다.
+	result = &finding{name: "file test/base.go"}
+	if _, err := os.Stat(filepath.Join(utils.GetTestDir(), "base.go")); err != nil {
+		result.problem = "missing, go mod tidy may drop go-hardhat from go.mod"
+	}
+	return append(results, result)
+}
+
+// checkRemappings checks that the remappings are prefix=path, the paths existing.
+func checkRemappings() []*finding {
+	bytes, err := os.ReadFile(utils.GetRemappingsFilePath())
+	if err != nil {
+		return nil
+	}
+	results := make([]*finding, 0)
+	for i, line := range strings.Split(string(bytes), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		result := &finding{name: fmt.Sprintf("remapping %d %s", i+1, line)}
+		results = append(results, result)
+
+		prefix, path, ok := strings.Cut(line, "=")
+		if !ok || prefix == "" || path == "" || strings.Contains(path, "=") {
+			result.problem = "expected prefix=path"
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(utils.GetContractDir(), path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			result.problem = fmt.Sprintf("%s does not exist", path)
+		}
+	}
+	return results
+}
+
+// checkSolc checks the installed solc versions and, once bms compile ran, that the
+// version it used is installed and the bindings are up to date.
+func checkSolc() []*finding {
+	installed := make([]string, 0)
+	for _, version := range versions.SortVersions(versions.GetInstalled()) {
+		installed = append(installed, version.String())
+	}
+	result := &finding{name: "solc installed " + strings.Join(installed, ", ")}
+	if len(installed) == 0 {
+		result.name = "solc installed"
+		result.problem = "none, bms compile <version> installs it"
+	}
+	results := []*finding{result}
+
+	opts, stale, err := compile.Stale()
+	if err != nil {
+		return append(results, &finding{name: "compile", problem: err.Error()})
+	}
+	if opts == nil {
+		return results
+	}
+	if opts.Version != "" {
+		version := opts.Version
+		result := &finding{name: "solc " + version}
+		if _, ok := versions.GetInstalled()[version]; !ok {
+			result.problem = "used by bms compile, not installed"
+			result.fix = func() error { return utils.InstallSolc(version) }
+		}
+		results = append(results, result)
+	}
+	result = &finding{name: "abis"}
+	if stale {
+		result.problem = "contracts changed since the last compilation"
+		result.fix = func() error { return compile.Run(opts) }
+	}
+	return append(results, result)
+}
@@ -2,6 +2,7 @@ package init
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -14,10 +15,59 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+const (
+	TEMPLATE_FLAG_NAME string = "template"
+	YES_FLAG_NAME      string = "yes"
+	NO_TIDY_FLAG_NAME  string = "no-tidy"
+	DIR_FLAG_NAME      string = "dir"
+)
+
 var Command *cli.Command = &cli.Command{
-	Name: "init",
+	Name:  "init",
+	Usage: "Create a bms project, or add bms to an existing Go module",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:    TEMPLATE_FLAG_NAME,
+			Aliases: []string{"t"},
+			Value:   "empty",
+			Usage:   fmt.Sprintf("project template, one of %s, a directory or a git repository", strings.Join(Templates, ", ")),
+		}, &cli.BoolFlag{
+			Name:    YES_FLAG_NAME,
+			Aliases: []string{"y"},
+			Usage:   "never prompt, the module path defaulting to the name of the directory",
+		}, &cli.BoolFlag{
+			Name:  NO_TIDY_FLAG_NAME,
+			Usage: "skip go mod tidy",
+		}, &cli.StringFlag{
+			Name:  DIR_FLAG_NAME,
+			Usage: "directory of the project, created if missing (default: current directory)",
+		},
+	}, toolingFlags...),
 	Action: func(ctx *cli.Context) error {
-		if err := initGoModule(ctx.Args().First()); err != nil {
+		// go.mod 를 만들기 전에 템플릿을 확인한다.
+		templateName := ctx.String(TEMPLATE_FLAG_NAME)
+		if err := checkTemplate(templateName); err != nil {
+			return err
+		}
+		if dir := ctx.String(DIR_FLAG_NAME); dir != "" {
+			if local, err := filepath.Abs(templateName); err == nil && !isGitURL(templateName) && !isBuiltin(templateName) {
+				templateName = local
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return errors.Wrap(err, dir)
+			}
+			if err := os.Chdir(dir); err != nil {
+				return errors.Wrap(err, dir)
+			}
+		}
+
+		// 프로젝트 루트는 --root 와 상위 디렉토리에 관계없이 init 을 실행한 디렉토리
+		if err := utils.SetRootPath("."); err != nil {
+			return err
+		}
+
+		report := new(report)
+		if err := initGoModule(ctx.Args().First(), ctx.Bool(YES_FLAG_NAME), report); err != nil {
 			return errors.Wrap(err, "fail to init project")
 		}
 
@@ -26,19 +76,70 @@ var Command *cli.Command = &cli.Command{
 		}
 
 		// 기본 디렉토리, 파일을 생성한다.
-		if err := makeDefaultFS(); err != nil {
+		if err := makeDefaultFS(templateName, report); err != nil {
 			return err
 		}
+		// 프로젝트 설정에서 에디터, 도구 설정 파일을 생성한다.
+		if err := writeTooling(ctx, report); err != nil {
+			return errors.Wrap(err, "tooling")
+		}
+
+		report.print()
 
-		return exec.Command("go", "mod", "tidy").Run()
+		// 템플릿의 테스트가 사용하는 abis 패키지는 컴파일 전에는 없으므로 -e 로 실행한다.
+		if !ctx.Bool(NO_TIDY_FLAG_NAME) {
+			return goCommand("mod", "tidy", "-e")
+		}
+		return nil
 	},
-	ArgsUsage: "<module-path>",
+	ArgsUsage: "[module-path]",
 }
 
-func initGoModule(modulepath string) error {
+// report is the files bms init created and the existing ones it left untouched.
+type report struct {
+	created, skipped []string
+}
+
+func (r *report) print() {
+	for _, file := range r.created {
+		fmt.Println("created ", file)
+	}
+	for _, file := range r.skipped {
+		fmt.Println("skipped ", file, "(exists)")
+	}
+	fmt.Printf("%d files created, %d skipped\n", len(r.created), len(r.skipped))
+}
+
+// initGoModule creates the go.mod, unless there is one, and requires go-hardhat and
+// go-ethereum.
+func initGoModule(modulepath string, yes bool, r *report) error {
+	if _, err := os.Stat("go.mod"); err == nil {
+		r.skipped = append(r.skipped, "go.mod")
+		// 기존 모듈에는 버전이 다른 go-hardhat 과 없는 go-ethereum 만 추가한다.
+		if version, _ := moduleVersion(utils.ModulePath); version != utils.AppVersion && version != utils.LocalVersion {
+			if err := goCommand("get", utils.ModulePath+"@"+utils.AppVersion); err != nil {
+				return err
+			}
+		}
+		if version, _ := moduleVersion(utils.EthereumModulePath); version == "" {
+			if err := goCommand("get", utils.EthereumModulePath+"@"+utils.EthereumVersion); err != nil {
+				return err
+			}
+		}
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
 	if modulepath == "" {
-		if path, err := prompt.Stdin.PromptInput("Go module path:"); err != nil {
-			return nil
+		if yes {
+			wd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			modulepath = filepath.Base(wd)
+		} else if path, err := prompt.Stdin.PromptInput("Go module path:"); err != nil {
+			return errors.Wrap(err, "module path")
 		} else {
 			modulepath = strings.TrimSpace(path)
 		}
@@ -48,87 +149,58 @@ func initGoModule(modulepath string) error {
 		return fmt.Errorf("%s is invalid go mod path", modulepath)
 	}
 
-	err := exec.Command("go", "mod", "init", modulepath).Run()
-	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("go mod init %s", modulepath))
-	}
-
-	bmspath := fmt.Sprintf("github.com/bang9ming9/go-hardhat@%s", utils.AppVersion)
-	if err := exec.Command("go", "get", bmspath).Run(); err != nil {
-		return errors.Wrap(err, fmt.Sprintf("go get %s", modulepath))
+	if err := goCommand("mod", "init", modulepath); err != nil {
+		return err
 	}
+	r.created = append(r.created, "go.mod")
 
-	ethereumpath := fmt.Sprintf("github.com/ethereum/go-ethereum@%s", utils.EthereumVersion)
-	if err := exec.Command("go", "get", ethereumpath).Run(); err != nil {
-		return errors.Wrap(err, fmt.Sprintf("go get %s", modulepath))
+	if err := goCommand("get", utils.ModulePath+"@"+utils.AppVersion); err != nil {
+		return err
 	}
-
-	return nil
+	return goCommand("get", utils.EthereumModulePath+"@"+utils.EthereumVersion)
 }
 
-func makeDefaultFS() error {
-	contractsDir := utils.GetContractDir()
-	if _, err := os.Stat(contractsDir); errors.Is(err, os.ErrNotExist) {
-		if err := os.Mkdir(contractsDir, 0755); err != nil {
-			return errors.Wrap(err, contractsDir)
-		}
+// moduleVersion is utils.ModuleVersion of the go.mod of the working directory, the root
+// of the project not being set yet.
+func moduleVersion(module string) (string, error) {
+	if err := utils.SetDirPath(); err != nil {
+		return "", err
 	}
+	return utils.ModuleVersion(module)
+}
 
-	testDir := utils.GetTestDir()
-	if _, err := os.Stat(testDir); errors.Is(err, os.ErrNotExist) {
-		if err := os.Mkdir(testDir, 0755); err != nil {
-			return errors.Wrap(err, testDir)
-		}
+// goCommand runs the go command, its output being the error if it fails.
+func goCommand(args ...string) error {
+	if output, err := exec.Command("go", args...).CombinedOutput(); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("go %s: %s", strings.Join(args, " "), strings.TrimSpace(string(output))))
 	}
+	return nil
+}
 
-	remapping := utils.GetRemappingsFilePath()
-	if _, err := os.Stat(remapping); errors.Is(err, os.ErrNotExist) {
-		if err := os.WriteFile(remapping, []byte{}, 0644); err != nil {
-			return errors.Wrap(err, fmt.Sprintf("create %s", remapping))
-		}
+// makeDefaultFS writes the base files and the files of the template, skipping the
+// existing ones.
+func makeDefaultFS(templateName string, r *report) error {
+	module, err := utils.MainModule()
+	if err != nil {
+		return errors.Wrap(err, "utils.MainModule")
 	}
-
-	prettierrc := filepath.Join(contractsDir, ".prettierrc")
-	if _, err := os.Stat(prettierrc); errors.Is(err, os.ErrNotExist) {
-		if err := os.WriteFile(prettierrc, []byte(`
-{
-	"overrides": [
-		{
-		"files": "*.sol",
-		"options": {
-			"printWidth": 80,
-			"tabWidth": 4,
-			"useTabs": true,
-			"singleQuote": false,
-			"bracketSpacing": true,
-			"explicitTypes": "always"
-		}
+	data := &templateData{Module: module, AppVersion: utils.AppVersion}
+
+	for _, name := range []string{"base", templateName} {
+		var files fs.FS
+		cleanup := func() {}
+		if name == "base" {
+			if files, err = fs.Sub(templates, "templates/base"); err != nil {
+				return err
+			}
+		} else if files, cleanup, err = loadTemplate(name); err != nil {
+			return err
 		}
-	]
-}`), 0644); err != nil {
-			return errors.Wrap(err, fmt.Sprintf("create %s", prettierrc))
+		err := writeTemplate(files, data, r)
+		cleanup()
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("template %s", name))
 		}
 	}
-
-	basetest := filepath.Join(testDir, "base.go")
-	if _, err := os.Stat(basetest); errors.Is(err, os.ErrNotExist) {
-		if err := os.WriteFile(basetest, []byte(`package test
-
-import (
-	"github.com/bang9ming9/go-hardhat/bms"
-	butils "github.com/bang9ming9/go-hardhat/bms/utils"
-	"github.com/ethereum/go-ethereum/common"
-)
-
-var (
-	_ = common.Big1
-	_ = bms.ChainID
-	_ = butils.ErrEventNotFind
-)
-`), 0644); err != nil {
-			return errors.Wrap(err, fmt.Sprintf("create %s", basetest))
-		}
-	}
-
 	return nil
 }
@@ -0,0 +1,145 @@
+package init
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/bang9ming9/go-hardhat/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// templates are the built-in templates, base being written for all of them. Files ending
+// with .tmpl are executed with templateData and written without the extension, so the
+// Go files of the templates are not part of this module.
+//
+//go:embed all:templates
+var templates embed.FS
+
+// Templates are the names of the built-in templates of bms init --template.
+var Templates = []string{"empty", "erc20", "erc721", "proxy"}
+
+const templateExt string = ".tmpl"
+
+type templateData struct {
+	// Module is the module path of the project, e.g. to import its abis.
+	Module     string
+	AppVersion string
+}
+
+// loadTemplate returns the files of a built-in template, a local directory or a git
+// repository, and the function to call once they are written.
+func loadTemplate(name string) (fs.FS, func(), error) {
+	if isBuiltin(name) {
+		sub, err := fs.Sub(templates, filepath.ToSlash(filepath.Join("templates", name)))
+		return sub, func() {}, err
+	}
+
+	if isGitURL(name) {
+		dir, err := os.MkdirTemp("", "bms-template-")
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup := func() { os.RemoveAll(dir) }
+		if output, err := exec.Command("git", "clone", "--depth", "1", name, dir).CombinedOutput(); err != nil {
+			cleanup()
+			return nil, nil, errors.Wrap(err, fmt.Sprintf("git clone %s: %s", name, strings.TrimSpace(string(output))))
+		}
+		return os.DirFS(dir), cleanup, nil
+	}
+
+	if err := checkTemplate(name); err != nil {
+		return nil, nil, err
+	}
+	return os.DirFS(name), func() {}, nil
+}
+
+// checkTemplate fails if the template is neither built-in, a directory nor a git
+// repository, without cloning the latter.
+func checkTemplate(name string) error {
+	if isGitURL(name) || isBuiltin(name) {
+		return nil
+	}
+	if info, err := os.Stat(name); err == nil && info.IsDir() {
+		return nil
+	}
+	return fmt.Errorf("unknown template %s, built-in: %s, or a directory or git repository", name, strings.Join(Templates, ", "))
+}
+
+func isBuiltin(name string) bool {
+	for _, builtin := range Templates {
+		if name == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+func isGitURL(name string) bool {
+	return strings.Contains(name, "://") || strings.HasPrefix(name, "git@") || strings.HasSuffix(name, ".git")
+}
+
+// writeTemplate writes the files of the template under the root of the project, skipping
+// the existing ones, and reports them.
+func writeTemplate(files fs.FS, data *templateData, r *report) error {
+	return fs.WalkDir(files, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.Name() == ".keep" {
+			return nil
+		}
+
+		content, err := fs.ReadFile(files, path)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(path, templateExt) {
+			tmpl, err := template.New(path).Parse(string(content))
+			if err != nil {
+				return errors.Wrap(err, path)
+			}
+			var buffer bytes.Buffer
+			if err := tmpl.Execute(&buffer, data); err != nil {
+				return errors.Wrap(err, path)
+			}
+			content = buffer.Bytes()
+		}
+		return writeFile(strings.TrimSuffix(path, templateExt), content, r)
+	})
+}
+
+// writeFile writes the file, its name relative to the root of the project, unless it
+// exists, and reports it.
+func writeFile(name string, content []byte, r *report) error {
+	rootpath, err := utils.GetRootPath()
+	if err != nil {
+		return errors.Wrap(err, "utils.GetRootPath")
+	}
+	target := filepath.Join(rootpath, filepath.FromSlash(name))
+	if _, err := os.Stat(target); err == nil {
+		r.skipped = append(r.skipped, name)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return errors.Wrap(err, filepath.Dir(target))
+	}
+	if err := os.WriteFile(target, content, 0644); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("create %s", target))
+	}
+	r.created = append(r.created, name)
+	return nil
+}
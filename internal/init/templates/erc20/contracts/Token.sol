@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+/// @notice A minimal ERC20 token, minted by its deployer.
+contract Token {
+	string public name;
+	string public symbol;
+	uint8 public constant decimals = 18;
+	uint256 public totalSupply;
+	address public minter;
+
+	mapping(address => uint256) public balanceOf;
+	mapping(address => mapping(address => uint256)) public allowance;
+
+	event Transfer(address indexed from, address indexed to, uint256 value);
+	event Approval(
+		address indexed owner,
+		address indexed spender,
+		uint256 value
+	);
+
+	error Unauthorized(address caller);
+	error InsufficientBalance(address account, uint256 balance, uint256 needed);
+	error InsufficientAllowance(
+		address spender,
+		uint256 allowance,
+		uint256 needed
+	);
+
+	constructor(string memory name_, string memory symbol_) {
+		name = name_;
+		symbol = symbol_;
+		minter = msg.sender;
+	}
+
+	function mint(address to, uint256 amount) external {
+		if (msg.sender != minter) revert Unauthorized(msg.sender);
+		totalSupply += amount;
+		balanceOf[to] += amount;
+		emit Transfer(address(0), to, amount);
+	}
+
+	function transfer(address to, uint256 amount) external returns (bool) {
+		_transfer(msg.sender, to, amount);
+		return true;
+	}
+
+	function approve(address spender, uint256 amount) external returns (bool) {
+		allowance[msg.sender][spender] = amount;
+		emit Approval(msg.sender, spender, amount);
+		return true;
+	}
+
+	function transferFrom(
+		address from,
+		address to,
+		uint256 amount
+	) external returns (bool) {
+		uint256 allowed = allowance[from][msg.sender];
+		if (allowed != type(uint256).max) {
+			if (allowed < amount)
+				revert InsufficientAllowance(msg.sender, allowed, amount);
+			allowance[from][msg.sender] = allowed - amount;
+		}
+		_transfer(from, to, amount);
+		return true;
+	}
+
+	function _transfer(address from, address to, uint256 amount) internal {
+		uint256 balance = balanceOf[from];
+		if (balance < amount) revert InsufficientBalance(from, balance, amount);
+		unchecked {
+			balanceOf[from] = balance - amount;
+		}
+		balanceOf[to] += amount;
+		emit Transfer(from, to, amount);
+	}
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+interface IERC721Receiver {
+	function onERC721Received(
+		address operator,
+		address from,
+		uint256 tokenId,
+		bytes calldata data
+	) external returns (bytes4);
+}
+
+/// @notice A minimal ERC721 token, minted by its deployer.
+contract NFT {
+	string public name;
+	string public symbol;
+	uint256 public totalSupply;
+	address public minter;
+
+	mapping(uint256 => address) private _owners;
+	mapping(address => uint256) public balanceOf;
+	mapping(uint256 => address) public getApproved;
+	mapping(address => mapping(address => bool)) public isApprovedForAll;
+
+	event Transfer(
+		address indexed from,
+		address indexed to,
+		uint256 indexed tokenId
+	);
+	event Approval(
+		address indexed owner,
+		address indexed approved,
+		uint256 indexed tokenId
+	);
+	event ApprovalForAll(
+		address indexed owner,
+		address indexed operator,
+		bool approved
+	);
+
+	error Unauthorized(address caller);
+	error NonexistentToken(uint256 tokenId);
+	error IncorrectOwner(address from, uint256 tokenId);
+	error InvalidReceiver(address to);
+
+	constructor(string memory name_, string memory symbol_) {
+		name = name_;
+		symbol = symbol_;
+		minter = msg.sender;
+	}
+
+	function supportsInterface(bytes4 interfaceId) external pure returns (bool) {
+		return interfaceId == 0x80ac58cd || interfaceId == 0x01ffc9a7;
+	}
+
+	function ownerOf(uint256 tokenId) public view returns (address owner) {
+		owner = _owners[tokenId];
+		if (owner == address(0)) revert NonexistentToken(tokenId);
+	}
+
+	function mint(address to) external returns (uint256 tokenId) {
+		if (msg.sender != minter) revert Unauthorized(msg.sender);
+		if (to == address(0)) revert InvalidReceiver(to);
+		tokenId = ++totalSupply;
+		_owners[tokenId] = to;
+		balanceOf[to] += 1;
+		emit Transfer(address(0), to, tokenId);
+	}
+
+	function approve(address to, uint256 tokenId) external {
+		address owner = ownerOf(tokenId);
+		if (msg.sender != owner && !isApprovedForAll[owner][msg.sender])
+			revert Unauthorized(msg.sender);
+		getApproved[tokenId] = to;
+		emit Approval(owner, to, tokenId);
+	}
+
+	function setApprovalForAll(address operator, bool approved) external {
+		isApprovedForAll[msg.sender][operator] = approved;
+		emit ApprovalForAll(msg.sender, operator, approved);
+	}
+
+	function transferFrom(address from, address to, uint256 tokenId) public {
+		address owner = ownerOf(tokenId);
+		if (owner != from) revert IncorrectOwner(from, tokenId);
+		if (to == address(0)) revert InvalidReceiver(to);
+		if (
+			msg.sender != owner &&
+			msg.sender != getApproved[tokenId] &&
+			!isApprovedForAll[owner][msg.sender]
+		) revert Unauthorized(msg.sender);
+
+		delete getApproved[tokenId];
+		balanceOf[from] -= 1;
+		balanceOf[to] += 1;
+		_owners[tokenId] = to;
+		emit Transfer(from, to, tokenId);
+	}
+
+	function safeTransferFrom(
+		address from,
+		address to,
+		uint256 tokenId
+	) external {
+		safeTransferFrom(from, to, tokenId, "");
+	}
+
+	function safeTransferFrom(
+		address from,
+		address to,
+		uint256 tokenId,
+		bytes memory data
+	) public {
+		transferFrom(from, to, tokenId);
+		if (
+			to.code.length != 0 &&
+			IERC721Receiver(to).onERC721Received(
+				msg.sender,
+				from,
+				tokenId,
+				data
+			) !=
+			IERC721Receiver.onERC721Received.selector
+		) revert InvalidReceiver(to);
+	}
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+/// @notice The first implementation behind the Proxy.
+contract BoxV1 {
+	bool private initialized;
+	address public owner;
+	uint256 public value;
+
+	event ValueChanged(uint256 newValue);
+
+	error AlreadyInitialized();
+	error Unauthorized(address caller);
+
+	function initialize(address owner_) external {
+		if (initialized) revert AlreadyInitialized();
+		initialized = true;
+		owner = owner_;
+	}
+
+	function store(uint256 newValue) external {
+		if (msg.sender != owner) revert Unauthorized(msg.sender);
+		value = newValue;
+		emit ValueChanged(newValue);
+	}
+
+	function version() external pure virtual returns (string memory) {
+		return "v1";
+	}
+}
+
+/// @notice The upgrade of BoxV1, keeping its storage layout.
+contract BoxV2 is BoxV1 {
+	function increment() external {
+		if (msg.sender != owner) revert Unauthorized(msg.sender);
+		value += 1;
+		emit ValueChanged(value);
+	}
+
+	function version() external pure override returns (string memory) {
+		return "v2";
+	}
+}
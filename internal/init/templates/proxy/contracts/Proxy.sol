@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.20;
+
+/// @notice A minimal ERC1967 proxy, upgraded by its deployer.
+contract Proxy {
+	// bytes32(uint256(keccak256("eip1967.proxy.implementation")) - 1)
+	bytes32 private constant IMPLEMENTATION_SLOT =
+		0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc;
+	// bytes32(uint256(keccak256("eip1967.proxy.admin")) - 1)
+	bytes32 private constant ADMIN_SLOT =
+		0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103;
+
+	event Upgraded(address indexed newImplementation);
+
+	error Unauthorized(address caller);
+	error InvalidImplementation(address newImplementation);
+
+	constructor(address newImplementation, bytes memory data) {
+		address admin_ = msg.sender;
+		assembly {
+			sstore(ADMIN_SLOT, admin_)
+		}
+		_upgradeToAndCall(newImplementation, data);
+	}
+
+	function upgradeToAndCall(
+		address newImplementation,
+		bytes calldata data
+	) external {
+		if (msg.sender != admin()) revert Unauthorized(msg.sender);
+		_upgradeToAndCall(newImplementation, data);
+	}
+
+	function implementation() public view returns (address implementation_) {
+		assembly {
+			implementation_ := sload(IMPLEMENTATION_SLOT)
+		}
+	}
+
+	function admin() public view returns (address admin_) {
+		assembly {
+			admin_ := sload(ADMIN_SLOT)
+		}
+	}
+
+	function _upgradeToAndCall(
+		address newImplementation,
+		bytes memory data
+	) private {
+		if (newImplementation.code.length == 0)
+			revert InvalidImplementation(newImplementation);
+		assembly {
+			sstore(IMPLEMENTATION_SLOT, newImplementation)
+		}
+		emit Upgraded(newImplementation);
+
+		if (data.length != 0) {
+			(bool ok, bytes memory returndata) = newImplementation.delegatecall(
+				data
+			);
+			if (!ok) {
+				assembly {
+					revert(add(returndata, 32), mload(returndata))
+				}
+			}
+		}
+	}
+
+	function _delegate() private {
+		address implementation_ = implementation();
+		assembly {
+			calldatacopy(0, 0, calldatasize())
+			let ok := delegatecall(
+				gas(),
+				implementation_,
+				0,
+				calldatasize(),
+				0,
+				0
+			)
+			returndatacopy(0, 0, returndatasize())
+			switch ok
+			case 0 {
+				revert(0, returndatasize())
+			}
+			default {
+				return(0, returndatasize())
+			}
+		}
+	}
+
+	fallback() external payable {
+		_delegate();
+	}
+
+	receive() external payable {
+		_delegate();
+	}
+}
@@ -0,0 +1,177 @@
+package init
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bang9ming9/go-hardhat/internal/compile"
+	"github.com/bang9ming9/go-hardhat/internal/utils"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	TOOLING_FLAG_NAME   string = "tooling"
+	VSCODE_FLAG_NAME    string = "vscode"
+	GITIGNORE_FLAG_NAME string = "gitignore"
+	FOUNDRY_FLAG_NAME   string = "foundry"
+	MAKEFILE_FLAG_NAME  string = "makefile"
+)
+
+var toolingFlags []cli.Flag = []cli.Flag{
+	&cli.BoolFlag{
+		Name:  TOOLING_FLAG_NAME,
+		Usage: "generate all of the editor and tooling files below",
+	}, &cli.BoolFlag{
+		Name:  VSCODE_FLAG_NAME,
+		Usage: "generate .vscode/settings.json with the remappings and solc version",
+	}, &cli.BoolFlag{
+		Name:  GITIGNORE_FLAG_NAME,
+		Usage: "generate .gitignore for the generated files",
+	}, &cli.BoolFlag{
+		Name:  FOUNDRY_FLAG_NAME,
+		Usage: "generate foundry.toml with the remappings, also read by hardhat-foundry",
+	}, &cli.BoolFlag{
+		Name:  MAKEFILE_FLAG_NAME,
+		Usage: "generate a Makefile with compile and test targets",
+	},
+}
+
+// projectConfig is the config the tooling files are derived from: the solc version of
+// the compile options and the remappings, relative to the root of the project.
+type projectConfig struct {
+	solcVersion string
+	remappings  []string
+}
+
+func readProjectConfig() (*projectConfig, error) {
+	rootpath, err := utils.GetRootPath()
+	if err != nil {
+		return nil, err
+	}
+	conf := &projectConfig{remappings: make([]string, 0)}
+	if opts, err := compile.ReadOptions(); err == nil && opts.Version != "" {
+		conf.solcVersion, _ = utils.ToSolcVersion(opts.Version)
+	} else {
+		// 설정이 없으면 PATH 의 solc
+		conf.solcVersion, _ = utils.ToSolcVersion("")
+	}
+	for _, remapping := range utils.ReadRemappings() {
+		prefix, path, _ := strings.Cut(remapping, "=")
+		if rel, err := filepath.Rel(rootpath, path); err == nil {
+			path = filepath.ToSlash(rel) + "/"
+		}
+		conf.remappings = append(conf.remappings, prefix+"="+path)
+	}
+	return conf, nil
+}
+
+// writeTooling writes the tooling files selected by the flags, skipping the existing ones.
+func writeTooling(ctx *cli.Context, r *report) error {
+	all := ctx.Bool(TOOLING_FLAG_NAME)
+	selected := func(name string) bool { return all || ctx.Bool(name) }
+	if !selected(VSCODE_FLAG_NAME) && !selected(GITIGNORE_FLAG_NAME) && !selected(FOUNDRY_FLAG_NAME) && !selected(MAKEFILE_FLAG_NAME) {
+		return nil
+	}
+
+	conf, err := readProjectConfig()
+	if err != nil {
+		return err
+	}
+	if selected(VSCODE_FLAG_NAME) {
+		content, err := conf.vscodeSettings()
+		if err != nil {
+			return err
+		}
+		if err := writeFile(".vscode/settings.json", content, r); err != nil {
+			return err
+		}
+	}
+	if selected(GITIGNORE_FLAG_NAME) {
+		if err := writeFile(".gitignore", conf.gitignore(selected(FOUNDRY_FLAG_NAME)), r); err != nil {
+			return err
+		}
+	}
+	if selected(FOUNDRY_FLAG_NAME) {
+		if err := writeFile("foundry.toml", conf.foundryToml(), r); err != nil {
+			return err
+		}
+	}
+	if selected(MAKEFILE_FLAG_NAME) {
+		if err := writeFile("Makefile", makefile(), r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vscodeSettings are the settings of the Solidity extension (juanblanco.solidity).
+func (conf *projectConfig) vscodeSettings() ([]byte, error) {
+	settings := map[string]interface{}{
+		"solidity.remappings": conf.remappings,
+		"[solidity]": map[string]interface{}{
+			"editor.formatOnSave": true,
+		},
+	}
+	if conf.solcVersion != "" {
+		settings["solidity.compileUsingRemoteVersion"] = "v" + utils.SolcLongVersion(conf.solcVersion)
+	}
+	content, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+func (conf *projectConfig) gitignore(foundry bool) []byte {
+	var builder strings.Builder
+	builder.WriteString("# bms\n")
+	builder.WriteString("deployments/localhost/\n")
+	builder.WriteString("gas-report*.json\n")
+	builder.WriteString("\n# go test\n")
+	builder.WriteString("*.test\n")
+	builder.WriteString("*.out\n")
+	if foundry {
+		builder.WriteString("\n# foundry\n")
+		builder.WriteString("out/\n")
+		builder.WriteString("cache/\n")
+	}
+	return []byte(builder.String())
+}
+
+// foundryToml exports the remappings for foundry, and for hardhat through the
+// hardhat-foundry plugin.
+func (conf *projectConfig) foundryToml() []byte {
+	var builder strings.Builder
+	builder.WriteString("[profile.default]\n")
+	builder.WriteString("src = \"contracts\"\n")
+	builder.WriteString("out = \"out\"\n")
+	if conf.solcVersion != "" {
+		fmt.Fprintf(&builder, "solc_version = %q\n", conf.solcVersion)
+	}
+	builder.WriteString("remappings = [\n")
+	for _, remapping := range conf.remappings {
+		fmt.Fprintf(&builder, "  %q,\n", remapping)
+	}
+	builder.WriteString("]\n")
+	return []byte(builder.String())
+}
+
+func makefile() []byte {
+	return []byte(`.PHONY: compile test gas-report doctor
+
+# 마지막 bms compile 설정(abis/.compile.json)으로 컴파일
+compile:
+	bms compile
+
+test:
+	bms test
+
+gas-report:
+	bms test --gas-report
+
+doctor:
+	bms doctor
+`)
+}
@@ -0,0 +1,235 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/bms/bmsutils"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	HOST_FLAG_NAME       string = "host"
+	PORT_FLAG_NAME       string = "port"
+	ACCOUNTS_FLAG_NAME   string = "accounts"
+	BALANCE_FLAG_NAME    string = "balance"
+	BLOCK_TIME_FLAG_NAME string = "block-time"
+	FORK_FLAG_NAME       string = "fork"
+	FORK_BLOCK_FLAG_NAME string = "fork-block"
+	DATADIR_FLAG_NAME    string = "datadir"
+	STATE_DUMP_FLAG_NAME string = "state-dump"
+	STATE_LOAD_FLAG_NAME string = "state-load"
+)
+
+var Command *cli.Command = &cli.Command{
+	Name:  "node",
+	Usage: "Start a local development chain with HTTP and WebSocket JSON-RPC servers",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  HOST_FLAG_NAME,
+			Value: "127.0.0.1",
+			Usage: "host of the JSON-RPC server",
+		}, &cli.IntFlag{
+			Name:  PORT_FLAG_NAME,
+			Value: 8545,
+			Usage: "port of the JSON-RPC server, serving HTTP and WebSocket",
+		}, &cli.UintFlag{
+			Name:  ACCOUNTS_FLAG_NAME,
+			Value: 10,
+			Usage: "number of prefunded accounts of the wallet (bms.UseMnemonic)",
+		}, &cli.Uint64Flag{
+			Name:  BALANCE_FLAG_NAME,
+			Value: 10000,
+			Usage: "balance of the prefunded accounts in ether",
+		}, &cli.DurationFlag{
+			Name:  BLOCK_TIME_FLAG_NAME,
+			Usage: "seal blocks at this interval instead of on every transaction",
+		}, &cli.StringFlag{
+			Name:  FORK_FLAG_NAME,
+			Usage: "JSON-RPC endpoint to fork the state from",
+		}, &cli.Uint64Flag{
+			Name:  FORK_BLOCK_FLAG_NAME,
+			Usage: "block to fork from, the latest one by default",
+		}, &cli.StringFlag{
+			Name:  DATADIR_FLAG_NAME,
+			Usage: "directory to keep the chain in, resumed on restart",
+		}, &cli.StringFlag{
+			Name:  STATE_DUMP_FLAG_NAME,
+			Usage: "file to write the accounts of the chain to on shutdown, as a genesis alloc JSON",
+		}, &cli.StringFlag{
+			Name:  STATE_LOAD_FLAG_NAME,
+			Usage: "genesis alloc JSON file (see --state-dump) to start the chain with",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		// geth 의 INFO, WARN 로그는 출력하지 않는다.
+		log.SetDefault(log.NewLogger(log.NewTerminalHandlerWithLevel(os.Stderr, log.LevelError, true)))
+
+		options, keys, err := nodeOptions(ctx)
+		if err != nil {
+			return err
+		}
+		backend, err := bms.NewBackend(options...)
+		if err != nil {
+			return errors.Wrap(err, "bms.NewBackend")
+		}
+		defer backend.Close()
+		if backend.Resumed() && ctx.IsSet(STATE_LOAD_FLAG_NAME) {
+			return fmt.Errorf("--%s can't be applied to the chain resumed from --%s", STATE_LOAD_FLAG_NAME, DATADIR_FLAG_NAME)
+		}
+
+		if err := printAccounts(backend, keys); err != nil {
+			return err
+		}
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+		<-interrupt
+		fmt.Println("Shutting down")
+
+		if path := ctx.String(STATE_DUMP_FLAG_NAME); path != "" {
+			if err := dumpState(backend, path); err != nil {
+				return errors.Wrap(err, "state dump")
+			}
+			fmt.Println("State written to", path)
+		}
+		return nil
+	},
+}
+
+func nodeOptions(ctx *cli.Context) ([]bms.Option, []*keyedAccount, error) {
+	host, port := ctx.String(HOST_FLAG_NAME), ctx.Int(PORT_FLAG_NAME)
+	options := []bms.Option{
+		bms.WithHTTP(host, port),
+		bms.WithWS(host, port),
+		bms.WithConsoleLog(),
+		bms.WithLogger(stdout{}),
+	}
+
+	// 1. 지갑의 계정들에 잔액을 할당한다.
+	balance := bmsutils.ToWei(new(big.Int).SetUint64(ctx.Uint64(BALANCE_FLAG_NAME)))
+	alloc := make(core.GenesisAlloc)
+	signers := make([]*bind.TransactOpts, 0, ctx.Uint(ACCOUNTS_FLAG_NAME))
+	keys := make([]*keyedAccount, 0, ctx.Uint(ACCOUNTS_FLAG_NAME))
+	for i := uint32(0); i < uint32(ctx.Uint(ACCOUNTS_FLAG_NAME)); i++ {
+		key, err := bms.GetKeyAt(i)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "bms.GetKeyAt")
+		}
+		account := &keyedAccount{key: hexutil.Encode(crypto.FromECDSA(key))}
+		account.address = crypto.PubkeyToAddress(key.PublicKey).Hex()
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{Balance: balance}
+		signer, err := bind.NewKeyedTransactorWithChainID(key, bms.ChainID)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "bind.NewKeyedTransactorWithChainID")
+		}
+		signers = append(signers, signer)
+		keys = append(keys, account)
+	}
+	// eth_sendTransaction 은 할당된 계정들로 서명한다.
+	options = append(options, bms.WithGenesisAlloc(alloc), bms.WithSigners(signers...))
+	if path := ctx.String(STATE_LOAD_FLAG_NAME); path != "" {
+		loaded, err := loadState(path)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "state load")
+		}
+		// 덤프된 잔액이 --balance 보다 우선한다.
+		options = append(options, bms.WithGenesisAlloc(loaded))
+	}
+
+	// 2. 블록 생성 모드
+	if interval := ctx.Duration(BLOCK_TIME_FLAG_NAME); interval > 0 {
+		options = append(options, bms.WithIntervalMining(interval))
+	}
+
+	// 3. 체인 포크
+	if url := ctx.String(FORK_FLAG_NAME); url != "" {
+		var number *big.Int
+		if ctx.IsSet(FORK_BLOCK_FLAG_NAME) {
+			number = new(big.Int).SetUint64(ctx.Uint64(FORK_BLOCK_FLAG_NAME))
+		}
+		options = append(options, bms.WithFork(url, number))
+	} else if ctx.IsSet(FORK_BLOCK_FLAG_NAME) {
+		return nil, nil, fmt.Errorf("--%s requires --%s", FORK_BLOCK_FLAG_NAME, FORK_FLAG_NAME)
+	}
+
+	// 4. 체인 저장
+	if dir := ctx.String(DATADIR_FLAG_NAME); dir != "" {
+		options = append(options, bms.WithDataDir(dir))
+	}
+	return options, keys, nil
+}
+
+func loadState(path string) (core.GenesisAlloc, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	alloc := make(core.GenesisAlloc)
+	if err := json.Unmarshal(bytes, &alloc); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	return alloc, nil
+}
+
+func dumpState(backend *bms.Backend, path string) error {
+	alloc, err := backend.DumpState()
+	if err != nil {
+		return err
+	}
+	bytes, err := json.MarshalIndent(alloc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, bytes, 0644)
+}
+
+type keyedAccount struct {
+	address string
+	key     string
+}
+
+func printAccounts(backend *bms.Backend, keys []*keyedAccount) error {
+	fmt.Printf("Started HTTP and WebSocket JSON-RPC server at %s/ (chain id %d, %s)\n", backend.HTTPEndpoint(), bms.ChainID, backend.MiningMode())
+	if backend.Resumed() {
+		number, err := backend.BlockNumber(context.Background())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Resumed the chain at block %d\n", number)
+	}
+	fmt.Println()
+	fmt.Println("Accounts")
+	fmt.Println("========")
+	for i, account := range keys {
+		// 저장된 체인이나 불러온 상태에서는 잔액이 --balance 와 다를 수 있다.
+		balance, err := backend.BalanceAt(context.Background(), common.HexToAddress(account.address), nil)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Account #%d: %s (%s ETH)\n", i, account.address, bmsutils.ToEther(balance).String())
+		fmt.Printf("Private Key: %s\n\n", account.key)
+	}
+	fmt.Println("WARNING: these accounts and their private keys are for development only.")
+	return nil
+}
+
+// stdout prints the console.log messages and transaction errors of the chain.
+type stdout struct{}
+
+func (stdout) Log(args ...interface{}) {
+	fmt.Println(args...)
+}
@@ -0,0 +1,98 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/internal/utils"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	NETWORK_FLAG_NAME string = "network"
+	RPC_URL_FLAG_NAME string = "rpc-url"
+)
+
+// Flags select the network of the script, see Script.
+var Flags []cli.Flag = []cli.Flag{
+	&cli.StringFlag{
+		Name:  NETWORK_FLAG_NAME,
+		Value: bms.DefaultNetwork,
+		Usage: "network profile of bms.json, localhost being a local bms node",
+	}, &cli.StringFlag{
+		Name:  RPC_URL_FLAG_NAME,
+		Usage: "JSON-RPC endpoint overriding the one of the network",
+	},
+}
+
+var Command *cli.Command = &cli.Command{
+	Name:      "run",
+	Usage:     "Run a Go script with the bms.Env of a network",
+	ArgsUsage: "<script> [script args...]",
+	Flags:     Flags,
+	Action: func(ctx *cli.Context) error {
+		return Script(ctx)
+	},
+}
+
+// Script runs the script of the first argument with go run, the network of the flags
+// given to the environment the script reads with bms.NewEnv. The flags can also follow
+// the script, the other arguments are passed to it.
+func Script(ctx *cli.Context) error {
+	network, rpcURL := ctx.String(NETWORK_FLAG_NAME), ctx.String(RPC_URL_FLAG_NAME)
+	args := make([]string, 0, ctx.NArg())
+	for i := 0; i < ctx.NArg(); i++ {
+		arg := ctx.Args().Get(i)
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || (name != NETWORK_FLAG_NAME && name != RPC_URL_FLAG_NAME) {
+			args = append(args, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 == ctx.NArg() {
+				return fmt.Errorf("flag needs an argument: %s", arg)
+			}
+			i++
+			value = ctx.Args().Get(i)
+		}
+		if name == NETWORK_FLAG_NAME {
+			network = value
+		} else {
+			rpcURL = value
+		}
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("missing script, e.g. bms %s ./scripts/foo.go", ctx.Command.Name)
+	}
+	if err := utils.SetDirPath(); err != nil {
+		return errors.Wrap(err, "utils.SetDirPath")
+	}
+	utils.CheckModuleVersion()
+
+	// 스크립트를 실행하기 전에 네트워크 설정을 확인한다.
+	conf, err := bms.ReadConfig(utils.GetConfigFilePath())
+	if err != nil {
+		return errors.Wrap(err, "bms.ReadConfig")
+	}
+	if _, err := conf.Network(network); err != nil {
+		return err
+	}
+
+	// 스크립트는 bms.NewEnv 에서 환경변수로 네트워크 정보를 읽는다.
+	cmd := exec.Command("go", append([]string{"run", args[0]}, args[1:]...)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(),
+		bms.NetworkEnv+"="+network,
+		bms.RPCURLEnv+"="+rpcURL,
+		bms.DeploymentsDirEnv+"="+utils.GetDeploymentsDir(),
+		bms.ConfigFileEnv+"="+utils.GetConfigFilePath(),
+	)
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(err, "go run "+args[0])
+	}
+	return nil
+}
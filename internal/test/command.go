@@ -0,0 +1,211 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bang9ming9/go-hardhat/bms"
+	"github.com/bang9ming9/go-hardhat/internal/compile"
+	"github.com/bang9ming9/go-hardhat/internal/utils"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	GAS_REPORT_FLAG_NAME string = "gas-report"
+	TRACE_FLAG_NAME      string = "trace"
+	MATCH_FLAG_NAME      string = "match"
+)
+
+var Command *cli.Command = &cli.Command{
+	Name:      "test",
+	Usage:     "Compile the changed contracts and run the tests of the project",
+	ArgsUsage: "[packages, default ./test/...]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  GAS_REPORT_FLAG_NAME,
+			Usage: "print the gas report of the tests using bms.GasReportMain",
+		}, &cli.BoolFlag{
+			Name:  TRACE_FLAG_NAME,
+			Usage: "trace failed transactions, their errors carrying the call tree",
+		}, &cli.StringFlag{
+			Name:    MATCH_FLAG_NAME,
+			Aliases: []string{"m"},
+			Usage:   "run only the tests matching the regular expression (go test -run)",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if err := utils.SetDirPath(); err != nil {
+			return errors.Wrap(err, "utils.SetDirPath")
+		}
+		rootpath, err := utils.GetRootPath()
+		if err != nil {
+			return errors.Wrap(err, "utils.GetRootPath")
+		}
+		utils.CheckModuleVersion()
+
+		// 1. 마지막 컴파일 이후 변경된 컨트랙트가 있으면 다시 컴파일
+		opts, stale, err := compile.Stale()
+		if err != nil {
+			return errors.Wrap(err, "compile.Stale")
+		}
+		if stale {
+			fmt.Println("Compiling the contracts changed since the last compilation")
+			if err := compile.Run(opts); err != nil {
+				return errors.Wrap(err, "compile.Run")
+			}
+		}
+
+		// 2. go test 인자와 환경변수 설정
+		args := []string{"test", "-json"}
+		if ctx.IsSet(MATCH_FLAG_NAME) {
+			args = append(args, "-run", ctx.String(MATCH_FLAG_NAME))
+		}
+		env := os.Environ()
+		if ctx.Bool(TRACE_FLAG_NAME) {
+			env = append(env, bms.TracingEnv+"=1")
+		}
+		var reportDir string
+		if ctx.Bool(GAS_REPORT_FLAG_NAME) {
+			if reportDir, err = os.MkdirTemp("", "bms-gas-report-"); err != nil {
+				return err
+			}
+			defer os.RemoveAll(reportDir)
+			// 캐시된 결과는 리포트를 남기지 않는다.
+			args = append(args, "-count=1")
+			env = append(env, bms.GasReportEnv+"="+reportDir)
+		}
+		if ctx.NArg() == 0 {
+			args = append(args, "./test/...")
+		} else {
+			args = append(args, ctx.Args().Slice()...)
+		}
+
+		// 3. go test 실행 및 결과 요약
+		cmd := exec.Command("go", args...)
+		cmd.Dir, cmd.Env, cmd.Stderr = rootpath, env, os.Stderr
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return errors.Wrap(err, "go test")
+		}
+		results := summarize(stdout, os.Stdout)
+		runErr := cmd.Wait()
+		results.print(os.Stdout)
+
+		// 4. 가스 리포트 출력
+		if reportDir != "" {
+			if files, _ := filepath.Glob(filepath.Join(reportDir, "*.json")); len(files) == 0 {
+				fmt.Fprintln(os.Stderr, "warning: no gas report, the TestMain of the tests must run bms.GasReportMain")
+			} else if report, err := bms.ReadGasReport(reportDir); err != nil {
+				return errors.Wrap(err, "bms.ReadGasReport")
+			} else {
+				fmt.Println()
+				if err := report.WriteTable(os.Stdout); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(results.failed) != 0 {
+			return fmt.Errorf("%d tests failed", len(results.failed))
+		}
+		if runErr != nil {
+			return errors.Wrap(runErr, "go test")
+		}
+		return nil
+	},
+}
+
+// event is an event of go test -json, see go doc test2json.
+type event struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+	Elapsed float64
+}
+
+type results struct {
+	passed, skipped int
+	failed          []string
+}
+
+// summarize prints the results of the packages and the output of the failed tests of
+// the go test -json stream, dropping the output of the passed ones.
+func summarize(r io.Reader, w io.Writer) *results {
+	results := &results{failed: make([]string, 0)}
+	outputs := make(map[string][]string)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Action == "" {
+			fmt.Fprintln(w, scanner.Text())
+			continue
+		}
+		key := e.Package + " " + e.Test
+		switch e.Action {
+		case "output":
+			outputs[key] = append(outputs[key], e.Output)
+		case "build-output":
+			fmt.Fprint(w, e.Output)
+		case "pass", "skip", "fail":
+			output := outputs[key]
+			delete(outputs, key)
+			if e.Test == "" {
+				printPackage(w, e, output)
+				continue
+			}
+			if e.Action == "fail" {
+				fmt.Fprint(w, strings.Join(output, ""))
+			}
+			if strings.Contains(e.Test, "/") { // 서브테스트는 상위 테스트로 집계
+				continue
+			}
+			switch e.Action {
+			case "pass":
+				results.passed++
+			case "skip":
+				results.skipped++
+			default:
+				results.failed = append(results.failed, e.Package+"."+e.Test)
+			}
+		}
+	}
+	return results
+}
+
+// printPackage prints the result line of the package, and all its output if it failed.
+func printPackage(w io.Writer, e event, output []string) {
+	switch e.Action {
+	case "fail":
+		fmt.Fprint(w, strings.Join(output, ""))
+	case "skip":
+		fmt.Fprintf(w, "?   \t%s\t[no test files]\n", e.Package)
+	default:
+		for _, line := range output {
+			if strings.HasPrefix(line, "ok") {
+				fmt.Fprint(w, line)
+				return
+			}
+		}
+		fmt.Fprintf(w, "ok  \t%s\t%.3fs\n", e.Package, e.Elapsed)
+	}
+}
+
+func (results *results) print(w io.Writer) {
+	fmt.Fprintf(w, "\n%d passed, %d failed, %d skipped\n", results.passed, len(results.failed), results.skipped)
+	for _, name := range results.failed {
+		fmt.Fprintln(w, "FAIL:", name)
+	}
+}
@@ -1,8 +1,8 @@
 package utils
 
 import (
+	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -18,29 +18,102 @@ var (
 	contract       string = ""
 	test           string = ""
 	abis           string = ""
+	deployments    string = ""
+	configpath     string = ""
 	remappingspath string = ""
 )
 
+// ErrNoProject is returned when no bms project is found from the working directory.
+var ErrNoProject = errors.New("no bms project found")
+
+// SetRootPath sets the root of the project instead of discovering it, e.g. with --root.
+func SetRootPath(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", abs)
+	}
+	rootpath = abs
+	return nil
+}
+
+// GetRootPath returns the root of the project: the closest directory, from the working
+// directory up, with a bms.json or a go.mod. In a go.work workspace, out of its modules,
+// the root is the only module used by the workspace that is a bms project.
 func GetRootPath() (string, error) {
 	if rootpath == "" {
-		cmd := exec.Command("go", "env")
-		output, err := cmd.Output()
+		wd, err := os.Getwd()
 		if err != nil {
-			return "", errors.Wrap(err, "cmd.Output(go mod)")
+			return "", errors.Wrap(err, "os.Getwd")
 		}
-		for _, line := range strings.Split(string(output), "\n") {
-			if strings.HasPrefix(line, "GOMOD=") {
-				rootpath = filepath.Dir(strings.TrimPrefix(line, "GOMOD='"))
-				if !strings.HasPrefix(rootpath, os.Getenv("HOME")) {
-					rootpath = ""
-				}
-				break
-			}
+		if rootpath, err = findRootPath(wd); err != nil {
+			return "", err
 		}
 	}
 	return rootpath, nil
 }
 
+func findRootPath(wd string) (string, error) {
+	for dir := wd; ; dir = filepath.Dir(dir) {
+		if isFile(filepath.Join(dir, "bms.json")) || isFile(filepath.Join(dir, "go.mod")) {
+			return dir, nil
+		}
+		if isFile(filepath.Join(dir, "go.work")) {
+			return workspaceRootPath(dir)
+		}
+		if filepath.Dir(dir) == dir {
+			return "", errors.Wrapf(ErrNoProject, "%s or its parents have no bms.json or go.mod, run bms init or use --root", wd)
+		}
+	}
+}
+
+// workspaceRootPath returns the module used by the go.work of dir that is a bms project,
+// having a bms.json or a contracts directory.
+func workspaceRootPath(dir string) (string, error) {
+	gowork := filepath.Join(dir, "go.work")
+	directives, err := readDirectives(gowork)
+	if err != nil {
+		return "", err
+	}
+	projects := make([]string, 0)
+	for _, fields := range directives {
+		if len(fields) < 2 || fields[0] != "use" {
+			continue
+		}
+		module := fields[1]
+		if !filepath.IsAbs(module) {
+			module = filepath.Join(dir, module)
+		}
+		if isFile(filepath.Join(module, "bms.json")) || isDir(filepath.Join(module, "contracts")) {
+			projects = append(projects, module)
+		}
+	}
+	switch len(projects) {
+	case 1:
+		return projects[0], nil
+	case 0:
+		return "", errors.Wrapf(ErrNoProject, "%s uses no module with a bms.json or contracts, use --root", gowork)
+	default:
+		return "", fmt.Errorf("%s uses several bms projects (%s), run bms in one of them or use --root", gowork, strings.Join(projects, ", "))
+	}
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
 func SetDirPath() error {
 	rootpath, err := GetRootPath()
 	if err != nil {
@@ -52,6 +125,8 @@ func SetDirPath() error {
 	contract = filepath.Join(rootpath, "contracts")
 	test = filepath.Join(rootpath, "test")
 	abis = filepath.Join(rootpath, "abis")
+	deployments = filepath.Join(rootpath, "deployments")
+	configpath = filepath.Join(rootpath, "bms.json")
 	remappingspath = filepath.Join(contract, "remappings.txt")
 	return nil
 }
@@ -68,6 +143,14 @@ func GetABIsDir() string {
 	return abis
 }
 
+func GetDeploymentsDir() string {
+	return deployments
+}
+
+func GetConfigFilePath() string {
+	return configpath
+}
+
 func GetRemappingsFilePath() string {
 	return remappingspath
 }
@@ -3,19 +3,121 @@ package utils
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strings"
 
+	solconfig "github.com/fabelx/go-solc-select/pkg/config"
 	"github.com/fabelx/go-solc-select/pkg/installer"
 	"github.com/fabelx/go-solc-select/pkg/versions"
 	"github.com/pkg/errors"
 )
 
 const (
-	AppVersion      = "v0.2.1"
-	EthereumVersion = "v1.13.12"
+	AppVersion         = "v0.2.1"
+	EthereumVersion    = "v1.13.12"
+	ModulePath         = "github.com/bang9ming9/go-hardhat"
+	EthereumModulePath = "github.com/ethereum/go-ethereum"
 )
 
+// LocalVersion is the version of a module replaced by a local directory.
+const LocalVersion string = "local"
+
+// ModuleVersion returns the version of the module the go.mod of the project requires,
+// "" if it requires none, or LocalVersion if it replaces it with a local directory.
+func ModuleVersion(module string) (string, error) {
+	rootpath, err := GetRootPath()
+	if err != nil {
+		return "", err
+	}
+	directives, err := readDirectives(filepath.Join(rootpath, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+
+	version := ""
+	for _, fields := range directives {
+		if len(fields) < 3 || fields[1] != module {
+			continue
+		}
+		switch fields[0] {
+		case "require":
+			version = fields[2]
+		case "replace":
+			// replace module [version] => target [version]
+			_, target, _ := strings.Cut(strings.Join(fields, " "), "=>")
+			if target := strings.Fields(target); len(target) == 2 {
+				return target[1], nil
+			}
+			return LocalVersion, nil
+		}
+	}
+	return version, nil
+}
+
+// readDirectives reads the directives of a go.mod or go.work file, each one being its
+// name and its fields, the ones of blocks included.
+func readDirectives(path string) ([][]string, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	directives, block := make([][]string, 0), ""
+	for _, line := range strings.Split(string(bytes), "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) == 2 && fields[1] == "(" {
+			block = fields[0]
+			continue
+		}
+		if fields[0] == ")" {
+			block = ""
+			continue
+		}
+		if block != "" {
+			fields = append([]string{block}, fields...)
+		}
+		for i := range fields {
+			fields[i] = strings.Trim(fields[i], `"`)
+		}
+		directives = append(directives, fields)
+	}
+	return directives, nil
+}
+
+// MainModule returns the module path of the go.mod of the project.
+func MainModule() (string, error) {
+	rootpath, err := GetRootPath()
+	if err != nil {
+		return "", err
+	}
+	directives, err := readDirectives(filepath.Join(rootpath, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	for _, fields := range directives {
+		if len(fields) >= 2 && fields[0] == "module" {
+			return fields[1], nil
+		}
+	}
+	return "", errors.New("go.mod has no module directive")
+}
+
+// CheckModuleVersion warns when the project requires a version of go-hardhat other than
+// the one of bms, the tests and scripts of the project being built with the former.
+func CheckModuleVersion() {
+	version, err := ModuleVersion(ModulePath)
+	if err != nil || version == "" || version == AppVersion || version == LocalVersion {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: go.mod requires %s %s, bms is %s (bms doctor --fix)\n", ModulePath, version, AppVersion)
+}
+
 func InstallSolc(version string) error {
 	var err error
 	if version, err = ToSolcVersion(version); err != nil {
@@ -30,6 +132,20 @@ func InstallSolc(version string) error {
 	return installer.InstallSolc(version)
 }
 
+// SolcLongVersion returns the long version of an installed solc, e.g.
+// 0.8.24+commit.e11b9ed9, or the version itself if it is not installed.
+func SolcLongVersion(version string) string {
+	path := filepath.Join(solconfig.SolcArtifacts, "solc-"+version, "solc-"+version)
+	output, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return version
+	}
+	if long := regexp.MustCompile(`\d+\.\d+\.\d+\+commit\.[0-9a-f]+`).FindString(string(output)); long != "" {
+		return long
+	}
+	return version
+}
+
 func ToSolcVersion(version string) (string, error) {
 	if version == "" {
 		cmd := exec.Command("solc", "--version")
@@ -4,12 +4,20 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/bang9ming9/go-hardhat/internal/accounts"
 	"github.com/bang9ming9/go-hardhat/internal/compile"
+	"github.com/bang9ming9/go-hardhat/internal/deploy"
+	"github.com/bang9ming9/go-hardhat/internal/doctor"
 	initCommand "github.com/bang9ming9/go-hardhat/internal/init"
+	"github.com/bang9ming9/go-hardhat/internal/node"
+	"github.com/bang9ming9/go-hardhat/internal/run"
+	testCommand "github.com/bang9ming9/go-hardhat/internal/test"
 	"github.com/bang9ming9/go-hardhat/internal/utils"
 	"github.com/urfave/cli/v2"
 )
 
+const ROOT_FLAG_NAME string = "root"
+
 var (
 	app = cli.NewApp()
 )
@@ -27,9 +35,29 @@ func init() {
 		return err
 	}
 
+	app.Flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:    ROOT_FLAG_NAME,
+			EnvVars: []string{"BMS_ROOT"},
+			Usage:   "root of the project (default: the closest directory with a bms.json or go.mod)",
+		},
+	}
+	app.Before = func(ctx *cli.Context) error {
+		if root := ctx.String(ROOT_FLAG_NAME); root != "" {
+			return utils.SetRootPath(root)
+		}
+		return nil
+	}
+
 	app.Commands = append(app.Commands, []*cli.Command{
 		initCommand.Command,
 		compile.Command,
+		node.Command,
+		deploy.Command,
+		run.Command,
+		testCommand.Command,
+		doctor.Command,
+		accounts.Command,
 	}...)
 }
 